@@ -0,0 +1,45 @@
+// api/access_log.go
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// secretQueryParams are query parameters carrying credentials, kept out of the access log.
+var secretQueryParams = []string{"token", "resume"}
+
+// accessLogFormatter formats requests like gin's default logger, with secret query
+// parameters redacted.
+func accessLogFormatter(param gin.LogFormatterParams) string {
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		redactQuery(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// redactQuery replaces the values of secret query parameters in a request path.
+func redactQuery(path string) string {
+	base, rawQuery, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return base + "?REDACTED"
+	}
+	for _, key := range secretQueryParams {
+		if _, ok := query[key]; ok {
+			query.Set(key, "REDACTED")
+		}
+	}
+	return base + "?" + query.Encode()
+}
@@ -0,0 +1,66 @@
+// api/errors.go
+package api
+
+import (
+	"chat-websocket/model"
+	"chat-websocket/usecase"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reject reports a rejected frame to the client: a nack for message and dm actions, so the
+// sender can match it to its client_msg_id, and an error event otherwise.
+func reject(client *model.Client, msg model.Message, err error) {
+	if msg.Action == "message" || msg.Action == "dm" {
+		sendNack(client, msg, err)
+		return
+	}
+	sendError(client, msg.Action, msg.RoomID, err)
+}
+
+// sendError reports a rejected action to the client as an error event.
+func sendError(client *model.Client, action, roomID string, err error) {
+	sendFailure(client, model.NewEvent(model.EventError, roomID, ""), action, err)
+}
+
+// sendNack reports a rejected message or dm to the client as a nack event.
+func sendNack(client *model.Client, msg model.Message, err error) {
+	event := model.NewEvent(model.EventNack, msg.RoomID, "")
+	event.ClientMsgID = msg.ClientMsgID
+	sendFailure(client, event, msg.Action, err)
+}
+
+// sendFailure fills an error or nack event with err and sends it to the client.
+func sendFailure(client *model.Client, event *model.Event, action string, err error) {
+	ue := usecase.AsError(err)
+	_ = event.SetPayload(model.ErrorPayload{
+		Code:         ue.Code,
+		Message:      ue.Message,
+		Action:       action,
+		RetryAfterMs: ue.RetryAfter.Milliseconds(),
+	})
+	if data, err := json.Marshal(event); err == nil {
+		client.Send(data)
+	}
+}
+
+// writeError writes err as a JSON error response with the status matching its code.
+func writeError(c *gin.Context, err error) {
+	ue := usecase.AsError(err)
+	status := http.StatusInternalServerError
+	switch ue.Code {
+	case model.ErrCodeInvalid:
+		status = http.StatusBadRequest
+	case model.ErrCodeForbidden:
+		status = http.StatusForbidden
+	case model.ErrCodeNotFound:
+		status = http.StatusNotFound
+	case model.ErrCodeConflict:
+		status = http.StatusConflict
+	case model.ErrCodeRateLimited:
+		status = http.StatusTooManyRequests
+	}
+	c.JSON(status, gin.H{"error": ue.Message, "code": ue.Code})
+}
@@ -0,0 +1,164 @@
+// api/message_handler.go
+package api
+
+import (
+	"chat-websocket/pkg/auth"
+	"chat-websocket/repository"
+	"chat-websocket/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MessageHandler serves the REST endpoints for message history and mentions.
+type MessageHandler struct {
+	MessageUseCase *usecase.MessageUseCase
+	MentionUseCase *usecase.MentionUseCase
+}
+
+// NewMessageHandler creates a new MessageHandler instance.
+func NewMessageHandler(messageUseCase *usecase.MessageUseCase, mentionUseCase *usecase.MentionUseCase) *MessageHandler {
+	return &MessageHandler{MessageUseCase: messageUseCase, MentionUseCase: mentionUseCase}
+}
+
+// GetRoomMessages handles GET /rooms/:id/messages?before=<id>&after=<id>&limit=N.
+func (h *MessageHandler) GetRoomMessages(c *gin.Context) {
+	page, ok := parseMessagePage(c)
+	if !ok {
+		return
+	}
+
+	claims := auth.ClaimsFromContext(c.Request.Context())
+	if err := h.MessageUseCase.Access.AuthorizeRead(c.Request.Context(), c.Param("id"), claims.Subject); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	messages, hasMore, err := h.MessageUseCase.GetRoomHistory(c.Request.Context(), c.Param("id"), page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load messages"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"messages": messages,
+		"has_more": hasMore,
+	})
+}
+
+// GetConversationMessages handles GET /conversations/:user_id/messages?before=<id>&after=<id>&limit=N,
+// returning the direct messages between the caller and the given user.
+func (h *MessageHandler) GetConversationMessages(c *gin.Context) {
+	page, ok := parseMessagePage(c)
+	if !ok {
+		return
+	}
+
+	claims := auth.ClaimsFromContext(c.Request.Context())
+	messages, hasMore, err := h.MessageUseCase.GetConversationHistory(c.Request.Context(), claims.Subject, c.Param("user_id"), page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load messages"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"messages": messages,
+		"has_more": hasMore,
+	})
+}
+
+// GetMentions handles GET /users/me/mentions?before=<id>&after=<id>&limit=N, returning a page of
+// the caller's mentions with the mentioning messages; pagination is by mention ID.
+func (h *MessageHandler) GetMentions(c *gin.Context) {
+	page, ok := parseMessagePage(c)
+	if !ok {
+		return
+	}
+
+	claims := auth.ClaimsFromContext(c.Request.Context())
+	mentions, hasMore, err := h.MentionUseCase.ListMentions(c.Request.Context(), claims.Subject, page)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"mentions": mentions,
+		"has_more": hasMore,
+	})
+}
+
+// GetMessageReplies handles GET /messages/:id/replies?before=<id>&after=<id>&limit=N, returning
+// a page of the replies in a message's thread.
+func (h *MessageHandler) GetMessageReplies(c *gin.Context) {
+	id, ok := parseMessageID(c)
+	if !ok {
+		return
+	}
+	page, ok := parseMessagePage(c)
+	if !ok {
+		return
+	}
+
+	claims := auth.ClaimsFromContext(c.Request.Context())
+	messages, hasMore, err := h.MessageUseCase.GetReplies(c.Request.Context(), claims.Subject, id, page)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"messages": messages,
+		"has_more": hasMore,
+	})
+}
+
+// GetMessageEdits handles GET /messages/:id/edits, returning a message's earlier versions oldest first.
+func (h *MessageHandler) GetMessageEdits(c *gin.Context) {
+	id, ok := parseMessageID(c)
+	if !ok {
+		return
+	}
+
+	claims := auth.ClaimsFromContext(c.Request.Context())
+	edits, err := h.MessageUseCase.GetEdits(c.Request.Context(), claims.Subject, id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"edits": edits})
+}
+
+// parseMessageID reads the :id path parameter, writing a 400 response on bad input.
+func parseMessageID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a positive message id"})
+		return 0, false
+	}
+	return id, true
+}
+
+// parseMessagePage reads the before/after/limit query parameters, writing a 400 response on bad input.
+func parseMessagePage(c *gin.Context) (repository.MessagePage, bool) {
+	var page repository.MessagePage
+	for _, p := range []struct {
+		name string
+		dst  *int64
+	}{{"before", &page.Before}, {"after", &page.After}} {
+		if v := c.Query(p.name); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": p.name + " must be a positive message id"})
+				return page, false
+			}
+			*p.dst = id
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return page, false
+		}
+		page.Limit = limit
+	}
+	return page, true
+}
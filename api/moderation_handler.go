@@ -0,0 +1,106 @@
+// api/moderation_handler.go
+package api
+
+import (
+	"chat-websocket/pkg/auth"
+	"chat-websocket/usecase"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModerationHandler serves the REST endpoints room owners and admins use to moderate.
+type ModerationHandler struct {
+	ModerationUseCase *usecase.ModerationUseCase
+}
+
+// NewModerationHandler creates a new ModerationHandler instance.
+func NewModerationHandler(moderationUseCase *usecase.ModerationUseCase) *ModerationHandler {
+	return &ModerationHandler{ModerationUseCase: moderationUseCase}
+}
+
+// moderationBody is the JSON body of the kick, ban and mute endpoints.
+type moderationBody struct {
+	UserID   string `json:"user_id" binding:"required"`
+	Duration int64  `json:"duration"` // Seconds; 0 means indefinitely.
+	Reason   string `json:"reason"`
+}
+
+// Kick handles POST /rooms/:id/kick.
+func (h *ModerationHandler) Kick(c *gin.Context) {
+	h.impose(c, h.ModerationUseCase.Kick)
+}
+
+// Ban handles POST /rooms/:id/bans.
+func (h *ModerationHandler) Ban(c *gin.Context) {
+	h.impose(c, h.ModerationUseCase.Ban)
+}
+
+// Mute handles POST /rooms/:id/mutes.
+func (h *ModerationHandler) Mute(c *gin.Context) {
+	h.impose(c, h.ModerationUseCase.Mute)
+}
+
+// Unban handles DELETE /rooms/:id/bans/:user_id.
+func (h *ModerationHandler) Unban(c *gin.Context) {
+	h.lift(c, h.ModerationUseCase.Unban)
+}
+
+// Unmute handles DELETE /rooms/:id/mutes/:user_id.
+func (h *ModerationHandler) Unmute(c *gin.Context) {
+	h.lift(c, h.ModerationUseCase.Unmute)
+}
+
+// ListSanctions handles GET /rooms/:id/sanctions.
+func (h *ModerationHandler) ListSanctions(c *gin.Context) {
+	claims := auth.ClaimsFromContext(c.Request.Context())
+	sanctions, err := h.ModerationUseCase.ListSanctions(c.Request.Context(), c.Param("id"), claims.Subject)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"room_id":   c.Param("id"),
+		"sanctions": sanctions,
+	})
+}
+
+// impose runs a kick, ban or mute described by the request body.
+func (h *ModerationHandler) impose(c *gin.Context, action func(context.Context, usecase.ModerationRequest) error) {
+	var body moderationBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+		return
+	}
+
+	claims := auth.ClaimsFromContext(c.Request.Context())
+	err := action(c.Request.Context(), usecase.ModerationRequest{
+		RoomID:      c.Param("id"),
+		ModeratorID: claims.Subject,
+		UserID:      body.UserID,
+		Duration:    time.Duration(body.Duration) * time.Second,
+		Reason:      body.Reason,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// lift runs an unban or unmute of the user in the path.
+func (h *ModerationHandler) lift(c *gin.Context, action func(context.Context, usecase.ModerationRequest) error) {
+	claims := auth.ClaimsFromContext(c.Request.Context())
+	err := action(c.Request.Context(), usecase.ModerationRequest{
+		RoomID:      c.Param("id"),
+		ModeratorID: claims.Subject,
+		UserID:      c.Param("user_id"),
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
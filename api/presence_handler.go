@@ -0,0 +1,77 @@
+// api/presence_handler.go
+package api
+
+import (
+	"chat-websocket/pkg/auth"
+	"chat-websocket/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresenceHandler serves the REST endpoints for cluster-wide presence.
+type PresenceHandler struct {
+	PresenceUseCase   *usecase.PresenceUseCase
+	RoomAccessUseCase *usecase.RoomAccessUseCase
+}
+
+// NewPresenceHandler creates a new PresenceHandler instance.
+func NewPresenceHandler(presenceUseCase *usecase.PresenceUseCase, roomAccessUseCase *usecase.RoomAccessUseCase) *PresenceHandler {
+	return &PresenceHandler{PresenceUseCase: presenceUseCase, RoomAccessUseCase: roomAccessUseCase}
+}
+
+// GetRoomMembers handles GET /rooms/:id/members.
+func (h *PresenceHandler) GetRoomMembers(c *gin.Context) {
+	roomID := c.Param("id")
+	claims := auth.ClaimsFromContext(c.Request.Context())
+	if err := h.RoomAccessUseCase.AuthorizeRead(c.Request.Context(), roomID, claims.Subject); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	members, err := h.PresenceUseCase.RoomMembers(c.Request.Context(), roomID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load room members"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"room_id": roomID,
+		"members": members,
+	})
+}
+
+// GetUserPresence handles GET /users/:id/presence.
+func (h *PresenceHandler) GetUserPresence(c *gin.Context) {
+	userID := c.Param("id")
+	ctx := c.Request.Context()
+	caller := auth.ClaimsFromContext(ctx).Subject
+	status, conns, err := h.PresenceUseCase.UserPresence(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load presence"})
+		return
+	}
+	// Device details are only for the user; see GET /users/me/sessions. Rooms are listed only
+	// where the caller may read them.
+	readable := make(map[string]bool)
+	for i := range conns {
+		conns[i].IP = ""
+		conns[i].UserAgent = ""
+		rooms := make([]string, 0, len(conns[i].Rooms))
+		for _, room := range conns[i].Rooms {
+			allowed, checked := readable[room]
+			if !checked {
+				allowed = h.RoomAccessUseCase.AuthorizeRead(ctx, room, caller) == nil
+				readable[room] = allowed
+			}
+			if allowed {
+				rooms = append(rooms, room)
+			}
+		}
+		conns[i].Rooms = rooms
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":     userID,
+		"status":      status,
+		"connections": conns,
+	})
+}
@@ -0,0 +1,31 @@
+// api/receipt_handler.go
+package api
+
+import (
+	"chat-websocket/pkg/auth"
+	"chat-websocket/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReceiptHandler serves the REST endpoints for read receipts.
+type ReceiptHandler struct {
+	ReceiptUseCase *usecase.ReceiptUseCase
+}
+
+// NewReceiptHandler creates a new ReceiptHandler instance.
+func NewReceiptHandler(receiptUseCase *usecase.ReceiptUseCase) *ReceiptHandler {
+	return &ReceiptHandler{ReceiptUseCase: receiptUseCase}
+}
+
+// GetUnread handles GET /users/me/unread, returning the caller's unread count in each of their rooms.
+func (h *ReceiptHandler) GetUnread(c *gin.Context) {
+	claims := auth.ClaimsFromContext(c.Request.Context())
+	rooms, err := h.ReceiptUseCase.UnreadCounts(c.Request.Context(), claims.Subject)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rooms": rooms})
+}
@@ -1,24 +1,76 @@
 package api
 
 import (
+	"chat-websocket/config"
+	"chat-websocket/pkg/auth"
 	"chat-websocket/usecase"
+	"log"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// UseCases bundles the use cases served by the API.
+type UseCases struct {
+	Room       *usecase.RoomUseCase
+	Message    *usecase.MessageUseCase
+	Presence   *usecase.PresenceUseCase
+	Access     *usecase.RoomAccessUseCase
+	Moderation *usecase.ModerationUseCase
+	RateLimit  *usecase.RateLimitUseCase
+	Typing     *usecase.TypingUseCase
+	Receipt    *usecase.ReceiptUseCase
+	Mention    *usecase.MentionUseCase
+	Inbox      *usecase.InboxUseCase
+	Session    *usecase.SessionUseCase
+}
+
 // NewRouter sets up the HTTP routes for the WebSocket chat service.
-func NewRouter(roomUseCase *usecase.RoomUseCase, messageUseCase *usecase.MessageUseCase) *gin.Engine {
-	router := gin.Default()
+// A nil verifier disables token authentication and trusts the sender_id query parameter.
+func NewRouter(cfg *config.Config, verifier *auth.Verifier, uc UseCases) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.LoggerWithFormatter(accessLogFormatter), gin.Recovery())
+	// Gin's ClientIP, used in request logs, believes the same proxies as the WebSocket handler.
+	proxies := make([]string, len(cfg.TrustedProxies))
+	for i, network := range cfg.TrustedProxies {
+		proxies[i] = network.String()
+	}
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		log.Printf("Failed to set trusted proxies: %v", err)
+	}
 
 	// Create a new WebSocketHandler with the provided use cases.
-	wsHandler := NewWebSocketHandler(roomUseCase, messageUseCase)
+	wsHandler := NewWebSocketHandler(cfg, uc)
 
-	// Define the route for WebSocket connections.
-	router.GET("/chat", func(c *gin.Context) {
+	// Define the route for WebSocket connections; authentication happens before the upgrade.
+	router.GET("/chat", authenticate(verifier), func(c *gin.Context) {
 		wsHandler.HandleConnection(c.Writer, c.Request)
 	})
 
+	// REST endpoints share the WebSocket authentication.
+	messageHandler := NewMessageHandler(uc.Message, uc.Mention)
+	presenceHandler := NewPresenceHandler(uc.Presence, uc.Access)
+	moderationHandler := NewModerationHandler(uc.Moderation)
+	receiptHandler := NewReceiptHandler(uc.Receipt)
+	sessionHandler := NewSessionHandler(uc.Session)
+	rest := router.Group("/", authenticate(verifier))
+	rest.GET("/rooms/:id/messages", messageHandler.GetRoomMessages)
+	rest.GET("/rooms/:id/members", presenceHandler.GetRoomMembers)
+	rest.POST("/rooms/:id/kick", moderationHandler.Kick)
+	rest.GET("/rooms/:id/sanctions", moderationHandler.ListSanctions)
+	rest.POST("/rooms/:id/bans", moderationHandler.Ban)
+	rest.DELETE("/rooms/:id/bans/:user_id", moderationHandler.Unban)
+	rest.POST("/rooms/:id/mutes", moderationHandler.Mute)
+	rest.DELETE("/rooms/:id/mutes/:user_id", moderationHandler.Unmute)
+	rest.GET("/users/me/unread", receiptHandler.GetUnread)
+	rest.GET("/users/me/mentions", messageHandler.GetMentions)
+	rest.GET("/users/me/sessions", sessionHandler.GetSessions)
+	rest.DELETE("/users/me/sessions/:conn_id", sessionHandler.RevokeSession)
+	rest.GET("/users/:id/presence", presenceHandler.GetUserPresence)
+	rest.GET("/conversations/:user_id/messages", messageHandler.GetConversationMessages)
+	rest.GET("/messages/:id/edits", messageHandler.GetMessageEdits)
+	rest.GET("/messages/:id/replies", messageHandler.GetMessageReplies)
+
 	// Set up Prometheus metrics endpoint.
 	router.GET("/metrics", prometheusHandler())
 
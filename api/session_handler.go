@@ -0,0 +1,41 @@
+// api/session_handler.go
+package api
+
+import (
+	"chat-websocket/pkg/auth"
+	"chat-websocket/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler serves the REST endpoints for a user's connected devices.
+type SessionHandler struct {
+	SessionUseCase *usecase.SessionUseCase
+}
+
+// NewSessionHandler creates a new SessionHandler instance.
+func NewSessionHandler(sessionUseCase *usecase.SessionUseCase) *SessionHandler {
+	return &SessionHandler{SessionUseCase: sessionUseCase}
+}
+
+// GetSessions handles GET /users/me/sessions, returning the caller's live connections.
+func (h *SessionHandler) GetSessions(c *gin.Context) {
+	claims := auth.ClaimsFromContext(c.Request.Context())
+	sessions, err := h.SessionUseCase.Devices(c.Request.Context(), claims.Subject)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession handles DELETE /users/me/sessions/:conn_id, disconnecting one of the caller's connections.
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	claims := auth.ClaimsFromContext(c.Request.Context())
+	if err := h.SessionUseCase.Revoke(c.Request.Context(), claims.Subject, c.Param("conn_id")); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -2,14 +2,20 @@
 package api
 
 import (
+	"chat-websocket/config"
 	"chat-websocket/model"
+	"chat-websocket/pkg/auth"
 	"chat-websocket/pkg/metrics"
+	"chat-websocket/pkg/netutil"
 	"chat-websocket/usecase"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"log"
 	"net"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -17,17 +23,50 @@ import (
 
 // WebSocketHandler handles WebSocket connections and incoming messages.
 type WebSocketHandler struct {
-	RoomUseCase    *usecase.RoomUseCase
-	MessageUseCase *usecase.MessageUseCase
-	Upgrader       websocket.Upgrader
+	RoomUseCase       *usecase.RoomUseCase
+	MessageUseCase    *usecase.MessageUseCase
+	PresenceUseCase   *usecase.PresenceUseCase
+	RoomAccessUseCase *usecase.RoomAccessUseCase
+	ModerationUseCase *usecase.ModerationUseCase
+	RateLimitUseCase  *usecase.RateLimitUseCase
+	TypingUseCase     *usecase.TypingUseCase
+	ReceiptUseCase    *usecase.ReceiptUseCase
+	MentionUseCase    *usecase.MentionUseCase
+	InboxUseCase      *usecase.InboxUseCase
+	SessionUseCase    *usecase.SessionUseCase
+	Upgrader          websocket.Upgrader
+	ClientOptions     model.ClientOptions
+	PongWait          time.Duration
+	NodeID            string
+	TrustedProxies    netutil.TrustedProxies
 }
 
 // NewWebSocketHandler creates a new WebSocketHandler instance.
-func NewWebSocketHandler(roomUseCase *usecase.RoomUseCase, messageUseCase *usecase.MessageUseCase) *WebSocketHandler {
+func NewWebSocketHandler(cfg *config.Config, uc UseCases) *WebSocketHandler {
 	return &WebSocketHandler{
-		RoomUseCase:    roomUseCase,
-		MessageUseCase: messageUseCase,
+		RoomUseCase:       uc.Room,
+		MessageUseCase:    uc.Message,
+		PresenceUseCase:   uc.Presence,
+		RoomAccessUseCase: uc.Access,
+		ModerationUseCase: uc.Moderation,
+		RateLimitUseCase:  uc.RateLimit,
+		TypingUseCase:     uc.Typing,
+		ReceiptUseCase:    uc.Receipt,
+		MentionUseCase:    uc.Mention,
+		InboxUseCase:      uc.Inbox,
+		SessionUseCase:    uc.Session,
+		ClientOptions: model.ClientOptions{
+			QueueSize:    cfg.SendQueueSize,
+			Policy:       model.OverflowPolicy(cfg.SendQueuePolicy),
+			WriteWait:    cfg.WriteWait,
+			PingInterval: cfg.PingInterval,
+		},
+		PongWait:       cfg.PongWait,
+		NodeID:         cfg.NodeID,
+		TrustedProxies: cfg.TrustedProxies,
 		Upgrader: websocket.Upgrader{
+			// Echo the token subprotocol so browsers accept the handshake.
+			Subprotocols: []string{auth.TokenProtocol},
 			CheckOrigin: func(r *http.Request) bool {
 				// Allow all origins for development; adjust in production.
 				return true
@@ -37,7 +76,21 @@ func NewWebSocketHandler(roomUseCase *usecase.RoomUseCase, messageUseCase *useca
 }
 
 // HandleConnection upgrades the HTTP connection to a WebSocket and processes messages.
+// The request must already carry authenticated claims (see authenticate).
 func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	connID, err := newConnectionID(h.NodeID)
+	if err != nil {
+		log.Printf("Failed to generate a connection ID: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	conn, err := h.Upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v\n", err)
@@ -49,98 +102,393 @@ func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Reque
 		conn.Close()
 	}()
 
-	// Set read deadline for heartbeat.
-	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	client := model.NewClient(connID, conn, h.ClientOptions)
+	client.IP = h.TrustedProxies.ClientIP(r)
+	client.UserAgent = r.UserAgent()
+	client.SenderID = claims.Subject
+	client.Name = claims.Name
+	client.Email = claims.Email
+	log.Printf("WebSocket connection %s from: %s, sender_id: %s", client.ID, client.IP, claims.Subject)
+
+	// The write pump pings every PingInterval; each pong extends the read deadline.
+	conn.SetReadDeadline(time.Now().Add(h.PongWait))
 	conn.SetPongHandler(func(string) error {
-		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-		return nil
+		client.MarkAlive()
+		return conn.SetReadDeadline(time.Now().Add(h.PongWait))
 	})
+	go client.WritePump()
 
-	senderID := r.URL.Query().Get("sender_id")
-	if senderID == "" {
-		log.Println("WebSocket connection rejected: sender_id is missing.")
-		conn.Close()
-		return
-	}
-	log.Printf("WebSocket connection request from: %s, sender_id: %s", conn.RemoteAddr(), senderID)
-
-	client := &model.Client{
-		ID:       conn.RemoteAddr().String(),
-		Conn:     conn,
-		SenderID: senderID,
+	// Close the socket once the token expires; the client must reconnect with a fresh token.
+	if !claims.ExpiresAt.IsZero() {
+		expiry := time.AfterFunc(time.Until(claims.ExpiresAt), func() {
+			log.Printf("Token expired for client %s, closing connection.", client.ID)
+			client.CloseWith(websocket.ClosePolicyViolation, "token expired")
+		})
+		defer expiry.Stop()
 	}
 
+	h.RoomUseCase.RegisterClient(context.Background(), client)
 	defer func() {
-		h.RoomUseCase.RemoveClient(context.Background(), client.ID)
+		h.TypingUseCase.Disconnect(context.Background(), client)
+		h.InboxUseCase.Disconnect(client)
+		h.SessionUseCase.Disconnect(context.Background(), client)
+		h.RoomUseCase.RemoveClient(context.Background(), client)
+		client.Close()
 		log.Printf("Client disconnected: %s\n", client.ID)
 	}()
+	h.startSession(context.Background(), client, r.URL.Query().Get("resume"))
+	h.InboxUseCase.Deliver(context.Background(), client)
 
 	// Channel to receive messages from the connection non-blockingly.
 	messageChan := make(chan []byte, 50)
 	go h.readMessages(conn, messageChan)
 
+	var strikes usecase.Strikes
+	var typing usecase.TypingBucket
+	closing := false
 	for msg := range messageChan {
+		if closing {
+			// Drain until the reader sees the close so that it does not block.
+			continue
+		}
+
 		var incoming model.Message
-		if err := json.Unmarshal(msg, &incoming); err != nil {
-			log.Printf("Invalid message format: %v\n", err)
+		parseErr := json.Unmarshal(msg, &incoming)
+
+		// Every frame is charged, including malformed ones, so garbage cannot flood the server either.
+		// Typing starts, which clients may send per keystroke, are charged to the connection's
+		// local typing bucket; they are coalesced before anything is published.
+		// Only a room the connection has joined is charged, so that a client cannot drain the
+		// bucket of a room it was never admitted to.
+		var disconnect bool
+		var err error
+		if parseErr == nil && incoming.Action == "typing_start" {
+			disconnect, err = h.RateLimitUseCase.CheckTyping(&strikes, &typing)
+		} else {
+			roomID := ""
+			if parseErr == nil && h.RoomUseCase.InRoom(client, incoming.RoomID) {
+				roomID = incoming.RoomID
+			}
+			disconnect, err = h.RateLimitUseCase.Check(context.Background(), &strikes, client.SenderID, client.IP, roomID)
+		}
+		if err != nil {
+			reject(client, incoming, err)
+			if disconnect {
+				log.Printf("Client %s keeps exceeding rate limits, closing connection.", client.ID)
+				metrics.RateLimitDisconnects.Inc()
+				client.CloseWith(websocket.ClosePolicyViolation, "rate limit exceeded")
+				closing = true
+			}
+			continue
+		}
+
+		if parseErr != nil {
+			log.Printf("Invalid message format: %v\n", parseErr)
+			sendError(client, "", "", &usecase.Error{Code: model.ErrCodeInvalid, Message: "invalid message format"})
 			continue
 		}
 		h.handleMessage(client, incoming)
 	}
 }
 
+// newConnectionID returns a connection ID unique across the cluster: the node ID followed by a
+// random suffix.
+func newConnectionID(nodeID string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return nodeID + "-" + hex.EncodeToString(b), nil
+}
+
 // readMessages reads messages from the WebSocket connection asynchronously.
-// When encountering errors (e.g. i/o timeout), it increases a counter and exits after reaching a threshold.
+// Any read error ends the loop; a timeout means no pong arrived within PongWait.
 func (h *WebSocketHandler) readMessages(conn *websocket.Conn, messageChan chan<- []byte) {
 	defer close(messageChan)
-	timeoutCount := 0
-	const maxTimeouts = 5 // Exit reading after 5 timeouts
 
 	for {
 		_, data, err := conn.ReadMessage()
 		if err != nil {
 			metrics.ReadErrors.Inc() // Increment error counter
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				timeoutCount++
-				log.Printf("WebSocket read timeout (%d/%d): %v", timeoutCount, maxTimeouts, err)
-				if timeoutCount >= maxTimeouts {
-					log.Printf("Maximum timeout reached. Closing connection.")
-					break
-				}
-				time.Sleep(1 * time.Minute)
-				continue
+				metrics.PongTimeouts.Inc()
+				log.Printf("WebSocket keepalive timeout for %s: %v", conn.RemoteAddr(), err)
 			} else {
 				log.Printf("WebSocket read error (non-timeout): %v", err)
-				break
 			}
+			return
 		}
-		// Reset timeout counter on successful read.
-		timeoutCount = 0
 		metrics.MessagesRead.Inc() // Increment messages read counter
 		messageChan <- data
 	}
 }
 
 // handleMessage processes the incoming message based on its action.
-// It checks if the RoomID is provided; if empty, it logs an error and ignores the message.
+// Rejected actions are reported back to the client as error events.
 func (h *WebSocketHandler) handleMessage(client *model.Client, msg model.Message) {
+	ctx := context.Background()
+	msg.SenderID = client.SenderID
+
+	// Direct messages, status changes and actions on existing messages are not addressed to a room.
+	switch msg.Action {
+	case "dm":
+		stored, duplicate, err := h.MessageUseCase.ProcessDirectMessage(ctx, msg)
+		acknowledge(client, msg, stored, duplicate, err)
+		return
+	case "status":
+		h.PresenceUseCase.SetStatus(ctx, client, msg.Status)
+		return
+	case "edit":
+		if _, err := h.MessageUseCase.EditMessage(ctx, client.SenderID, msg.MessageID, msg.Content); err != nil {
+			sendError(client, msg.Action, msg.RoomID, err)
+		}
+		return
+	case "delete":
+		if _, err := h.MessageUseCase.DeleteMessage(ctx, client.SenderID, msg.MessageID); err != nil {
+			sendError(client, msg.Action, msg.RoomID, err)
+		}
+		return
+	case "react":
+		if err := h.MessageUseCase.React(ctx, client.SenderID, msg.MessageID, msg.Emoji); err != nil {
+			sendError(client, msg.Action, msg.RoomID, err)
+		}
+		return
+	case "unreact":
+		if err := h.MessageUseCase.Unreact(ctx, client.SenderID, msg.MessageID, msg.Emoji); err != nil {
+			sendError(client, msg.Action, msg.RoomID, err)
+		}
+		return
+	case "subscribe_thread":
+		parent, err := h.MessageUseCase.GetThreadParent(ctx, client.SenderID, msg.MessageID)
+		if err != nil {
+			sendError(client, msg.Action, msg.RoomID, err)
+			return
+		}
+		h.RoomUseCase.SubscribeThread(ctx, client, parent)
+		h.SessionUseCase.Sync(ctx, client)
+		return
+	case "unsubscribe_thread":
+		h.RoomUseCase.UnsubscribeThread(ctx, client.ID, msg.MessageID)
+		h.SessionUseCase.Sync(ctx, client)
+		return
+	case "inbox_ack":
+		if err := h.InboxUseCase.Ack(ctx, client, msg.InboxID); err != nil {
+			sendError(client, msg.Action, msg.RoomID, err)
+		}
+		return
+	}
+
 	// Validate that RoomID is not empty.
 	if msg.RoomID == "" {
 		log.Printf("Error: RoomID is empty in message from client %s", client.ID)
+		reject(client, msg, &usecase.Error{Code: model.ErrCodeInvalid, Message: "room_id is required"})
 		return
 	}
 
-	msg.SenderID = client.SenderID
-
+	var err error
 	switch msg.Action {
 	case "join":
-		h.RoomUseCase.JoinRoom(context.Background(), client, msg.RoomID)
+		err = h.joinRoom(ctx, client, msg)
 	case "leave":
-		h.RoomUseCase.LeaveRoom(context.Background(), client.ID, msg.RoomID)
+		h.TypingUseCase.Stop(ctx, client, msg.RoomID)
+		h.RoomUseCase.LeaveRoom(ctx, client, msg.RoomID)
+		h.SessionUseCase.Sync(ctx, client)
+	case "typing_start":
+		// Typing indicators are relayed but never stored.
+		err = h.TypingUseCase.Start(ctx, client, msg.RoomID)
+	case "typing_stop":
+		h.TypingUseCase.Stop(ctx, client, msg.RoomID)
 	case "message":
 		// Process the message: save to DB and broadcast.
-		h.MessageUseCase.ProcessMessage(context.Background(), msg)
+		msg.RecipientID = ""
+		stored, duplicate, err := h.MessageUseCase.ProcessMessage(ctx, msg)
+		acknowledge(client, msg, stored, duplicate, err)
+		if err == nil {
+			// Sending a message ends the sender's typing indicator.
+			h.TypingUseCase.Stop(ctx, client, msg.RoomID)
+		}
+		if err == nil && !duplicate {
+			h.MentionUseCase.Notify(ctx, stored)
+		}
+		if err == nil && msg.ParentID != 0 {
+			// Replying follows the thread, so the sender sees the answers.
+			if parent, err := h.MessageUseCase.GetThreadParent(ctx, client.SenderID, msg.ParentID); err == nil {
+				h.RoomUseCase.SubscribeThread(ctx, client, parent)
+				h.SessionUseCase.Sync(ctx, client)
+			}
+		}
+		return
+	case "ack":
+		err = h.SessionUseCase.Advance(ctx, client, msg.RoomID, msg.LastID)
+	case "read":
+		// Reading a message implies having received it.
+		if err = h.ReceiptUseCase.MarkRead(ctx, msg.RoomID, client.SenderID, msg.ReadUpTo); err == nil {
+			_ = h.SessionUseCase.Advance(ctx, client, msg.RoomID, msg.ReadUpTo)
+		}
+	case "create":
+		if _, err = h.RoomAccessUseCase.CreateRoom(ctx, msg.RoomID, client.SenderID, msg.Visibility); err == nil {
+			err = h.joinRoom(ctx, client, msg)
+		}
+	case "invite":
+		err = h.RoomAccessUseCase.Invite(ctx, msg.RoomID, client.SenderID, msg.TargetID)
+	case "accept":
+		if err = h.RoomAccessUseCase.AcceptInvitation(ctx, msg.RoomID, client.SenderID); err == nil {
+			err = h.joinRoom(ctx, client, msg)
+		}
+	case "set_role":
+		err = h.RoomAccessUseCase.SetRole(ctx, msg.RoomID, client.SenderID, msg.TargetID, msg.Role)
+	case "kick":
+		err = h.ModerationUseCase.Kick(ctx, moderationRequest(msg))
+	case "ban":
+		err = h.ModerationUseCase.Ban(ctx, moderationRequest(msg))
+	case "unban":
+		err = h.ModerationUseCase.Unban(ctx, moderationRequest(msg))
+	case "mute":
+		err = h.ModerationUseCase.Mute(ctx, moderationRequest(msg))
+	case "unmute":
+		err = h.ModerationUseCase.Unmute(ctx, moderationRequest(msg))
 	default:
 		log.Printf("Unknown action: %s", msg.Action)
+		err = &usecase.Error{Code: model.ErrCodeInvalid, Message: "unknown action " + msg.Action}
+	}
+	if err != nil {
+		sendError(client, msg.Action, msg.RoomID, err)
+	}
+}
+
+// acknowledge answers a message or dm with an ack carrying the stored message's ID and
+// timestamp, or with a nack if it was rejected.
+func acknowledge(client *model.Client, msg model.Message, stored *model.Message, duplicate bool, err error) {
+	if err != nil {
+		sendNack(client, msg, err)
+		return
+	}
+
+	ack := model.NewEvent(model.EventAck, stored.RoomID, "")
+	ack.ID = stored.ID
+	ack.CreatedAt = stored.CreatedAt.UTC()
+	ack.ClientMsgID = stored.ClientMsgID
+	if duplicate {
+		_ = ack.SetPayload(model.AckPayload{Duplicate: true})
+	}
+	if data, err := json.Marshal(ack); err == nil {
+		client.Send(data)
+	}
+}
+
+// moderationRequest builds a moderation request from a kick, ban, unban, mute or unmute action.
+func moderationRequest(msg model.Message) usecase.ModerationRequest {
+	return usecase.ModerationRequest{
+		RoomID:      msg.RoomID,
+		ModeratorID: msg.SenderID,
+		UserID:      msg.TargetID,
+		Duration:    time.Duration(msg.Duration) * time.Second,
+		Reason:      msg.Reason,
+	}
+}
+
+// resumeReplayLimit bounds the replay of a resumed room that was empty when the client joined it.
+const resumeReplayLimit = 200
+
+// startSession issues a session to a newly registered client and announces it with a session
+// event. If the client presents the token of a recently closed session, that session's rooms are
+// rejoined with everything after the last acknowledged message replayed, and its threads are
+// followed again; an unknown or expired token is reported with an error and a fresh session.
+func (h *WebSocketHandler) startSession(ctx context.Context, client *model.Client, resumeToken string) {
+	var previous *model.Session
+	if resumeToken != "" {
+		var err error
+		if previous, err = h.SessionUseCase.Resume(ctx, client.SenderID, resumeToken); err != nil {
+			sendError(client, "resume", "", err)
+		}
+	}
+
+	session, err := h.SessionUseCase.Start(ctx, client)
+	if err != nil {
+		sendError(client, "resume", "", err)
+		return
+	}
+	event := model.NewEvent(model.EventSession, "", "")
+	_ = event.SetPayload(model.SessionPayload{
+		Token:          session.Token,
+		ResumeWindowMs: h.SessionUseCase.Window().Milliseconds(),
+		Resumed:        previous != nil,
+	})
+	if data, err := json.Marshal(event); err == nil {
+		client.Send(data)
+	}
+	if previous == nil {
+		return
+	}
+
+	roomIDs := make([]string, 0, len(previous.Rooms))
+	for roomID := range previous.Rooms {
+		roomIDs = append(roomIDs, roomID)
+	}
+	sort.Strings(roomIDs)
+	for _, roomID := range roomIDs {
+		join := model.Message{Action: "join", RoomID: roomID, SenderID: client.SenderID, SinceID: previous.Rooms[roomID]}
+		if join.SinceID == 0 {
+			// The room was empty when joined, so all of its messages are new to the client.
+			join.LastN = resumeReplayLimit
+		}
+		if err := h.joinRoom(ctx, client, join); err != nil {
+			sendError(client, join.Action, roomID, err)
+		}
+	}
+	for _, parentID := range previous.Threads {
+		if parent, err := h.MessageUseCase.GetThreadParent(ctx, client.SenderID, parentID); err == nil {
+			h.RoomUseCase.SubscribeThread(ctx, client, parent)
+		}
+	}
+}
+
+// joinRoom adds the client to a room and, if requested, replays missed history to it.
+// Live events are held back during the replay so that the client sees no gaps or duplicates.
+// The newest message replayed, or else the newest in the room, seeds the session's position.
+func (h *WebSocketHandler) joinRoom(ctx context.Context, client *model.Client, msg model.Message) error {
+	if msg.SinceID <= 0 && msg.LastN <= 0 {
+		if err := h.RoomUseCase.JoinRoom(ctx, client, msg.RoomID); err != nil {
+			return err
+		}
+		if lastID, err := h.MessageUseCase.LatestMessageID(ctx, msg.RoomID); err == nil && lastID > 0 {
+			_ = h.SessionUseCase.Advance(ctx, client, msg.RoomID, lastID)
+		}
+		h.SessionUseCase.Sync(ctx, client)
+		return nil
+	}
+
+	client.BeginReplay(msg.RoomID)
+	if err := h.RoomUseCase.JoinRoom(ctx, client, msg.RoomID); err != nil {
+		client.EndReplay(msg.RoomID, msg.SinceID)
+		return err
+	}
+
+	lastID := msg.SinceID
+	messages, truncated, err := h.MessageUseCase.GetReplay(ctx, msg.RoomID, msg.SinceID, msg.LastN)
+	if err != nil {
+		log.Printf("Failed to replay history for client %s in room %s: %v", client.ID, msg.RoomID, err)
+	}
+	for i := range messages {
+		data, err := json.Marshal(model.NewMessageEvent(&messages[i]))
+		if err != nil {
+			continue
+		}
+		client.Send(data)
+		if messages[i].ID > lastID {
+			lastID = messages[i].ID
+		}
+	}
+
+	done := model.NewEvent(model.EventReplayDone, msg.RoomID, "")
+	_ = done.SetPayload(model.ReplayPayload{Count: len(messages), LastID: lastID, Truncated: truncated})
+	if data, err := json.Marshal(done); err == nil {
+		client.Send(data)
+	}
+	client.EndReplay(msg.RoomID, lastID)
+	if lastID > 0 {
+		_ = h.SessionUseCase.Advance(ctx, client, msg.RoomID, lastID)
 	}
+	h.SessionUseCase.Sync(ctx, client)
+	return nil
 }
@@ -0,0 +1,111 @@
+// backends.go
+package main
+
+import (
+	"chat-websocket/config"
+	"chat-websocket/db"
+	"chat-websocket/redis"
+	"chat-websocket/repository"
+	"log"
+)
+
+// backends groups the storage and fan-out implementations selected by the BACKEND setting.
+type backends struct {
+	pubSub       redis.PubSubRepository
+	presence     redis.PresenceRepository
+	limiter      redis.RateLimiter
+	receiptCache redis.ReadReceiptCache
+	inboxStore   redis.InboxStore
+	sessions     redis.SessionStore
+	messages     repository.MessageRepository
+	rooms        repository.RoomRepository
+	sanctions    repository.SanctionRepository
+	receipts     repository.ReceiptRepository
+	mentions     repository.MentionRepository
+	inbox        repository.InboxRepository
+	clients      repository.ClientRepository
+
+	closers []func() error // Run in reverse order by Close.
+}
+
+// newBackends builds either the MySQL/Redis backends or the in-memory ones.
+func newBackends(cfg *config.Config) *backends {
+	switch cfg.Backend {
+	case "memory":
+		return newMemoryBackends(cfg)
+	case "standard":
+		return newStandardBackends(cfg)
+	default:
+		log.Fatalf("Unknown BACKEND %q (expected standard or memory)", cfg.Backend)
+		return nil
+	}
+}
+
+// newStandardBackends connects to MySQL and Redis.
+func newStandardBackends(cfg *config.Config) *backends {
+	b := &backends{}
+
+	// Initialize MySQL database.
+	dbConn := db.InitMySQL(cfg)
+
+	// Initialize Redis client.
+	redisClient := redis.NewRedisClient(cfg.RedisAddr, cfg.RedisPass, cfg.RedisDB)
+	b.closers = append(b.closers, redisClient.Close)
+
+	// Initialize the cross-node fan-out: Redis Pub/Sub or durable Redis Streams.
+	switch cfg.PubSubBackend {
+	case "streams":
+		b.pubSub = redis.NewStreamPubSubRepository(redisClient, cfg.NodeID, int64(cfg.StreamMaxLen))
+	case "pubsub":
+		b.pubSub = redis.NewPubSubRepository(redisClient)
+	default:
+		log.Fatalf("Unknown PUBSUB_BACKEND %q (expected pubsub or streams)", cfg.PubSubBackend)
+	}
+	b.closers = append(b.closers, b.pubSub.Close)
+	b.presence = redis.NewPresenceRepository(redisClient)
+	b.limiter = redis.NewRateLimiter(redisClient)
+	b.receiptCache = redis.NewReadReceiptCache(redisClient, cfg.ReceiptCacheTTL)
+	b.inboxStore = redis.NewInboxStore(redisClient, cfg.InboxCap, cfg.InboxTTL)
+	b.sessions = redis.NewSessionStore(redisClient)
+
+	// Initialize repositories.
+	b.messages = repository.NewMessageRepository(dbConn)
+	b.rooms = repository.NewRoomRepository(dbConn)
+	b.sanctions = repository.NewSanctionRepository(dbConn)
+	b.receipts = repository.NewReceiptRepository(dbConn)
+	b.mentions = repository.NewMentionRepository(dbConn)
+	b.inbox = repository.NewInboxRepository(dbConn)
+	b.clients = repository.NewClientRepository(dbConn)
+	return b
+}
+
+// newMemoryBackends keeps everything in process: a single-node dev/demo mode needing neither MySQL nor Redis.
+func newMemoryBackends(cfg *config.Config) *backends {
+	log.Println("Using in-memory backends; state is lost on restart and not shared between nodes.")
+	b := &backends{
+		pubSub:       redis.NewMemoryPubSubRepository(),
+		presence:     redis.NewMemoryPresenceRepository(),
+		limiter:      redis.NewMemoryRateLimiter(),
+		receiptCache: redis.NewMemoryReadReceiptCache(),
+		inboxStore:   redis.NewMemoryInboxStore(cfg.InboxCap),
+		sessions:     redis.NewMemorySessionStore(),
+		messages:     repository.NewMemoryMessageRepository(),
+		rooms:        repository.NewMemoryRoomRepository(),
+		sanctions:    repository.NewMemorySanctionRepository(),
+		receipts:     repository.NewMemoryReceiptRepository(),
+		mentions:     repository.NewMemoryMentionRepository(),
+		inbox:        repository.NewMemoryInboxRepository(),
+		clients:      repository.NewMemoryClientRepository(),
+	}
+	b.closers = append(b.closers, b.pubSub.Close)
+	return b
+}
+
+// Close releases the backends in reverse order of creation.
+func (b *backends) Close() {
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if err := b.closers[i](); err != nil {
+			log.Printf("Failed to close backend: %v", err)
+		}
+	}
+}
@@ -4,9 +4,8 @@ package main
 import (
 	"chat-websocket/api"
 	"chat-websocket/config"
-	"chat-websocket/db"
+	"chat-websocket/pkg/auth"
 	"chat-websocket/redis"
-	"chat-websocket/repository"
 	"chat-websocket/service"
 	"chat-websocket/usecase"
 	"context"
@@ -22,34 +21,74 @@ func main() {
 	// 1. Load configuration.
 	cfg := config.LoadConfig()
 
-	// 2. Initialize MySQL database.
-	dbConn := db.InitMySQL(cfg)
-	// dbConn can be used by repositories.
+	// 2. Initialize storage and cross-node fan-out (MySQL/Redis, or in-memory when BACKEND=memory).
+	b := newBackends(cfg)
+	defer b.Close()
+	pubSubRepo := b.pubSub
+	messageRepo := b.messages
+	// The client repository is not used by the use cases yet.
+	_ = b.clients
 
-	// 3. Initialize Redis client.
-	redisClient := redis.NewRedisClient(cfg.RedisAddr, cfg.RedisPass, cfg.RedisDB)
-	defer redisClient.Close()
-
-	// 4. Initialize Redis Pub/Sub repository.
-	pubSubRepo := redis.NewPubSubRepository(redisClient)
-
-	// 5. Initialize repositories.
-	messageRepo := repository.NewMessageRepository(dbConn)
-	// For clients, here we use the MySQL-based repository (you can replace with your own implementation)
-	_ = repository.NewClientRepository(dbConn)
-
-	// 6. Initialize services.
+	// 3. Initialize services.
 	messageService := service.NewMessageService(pubSubRepo)
 	_ = service.NewRoomService(pubSubRepo)
 
-	// 7. Initialize use cases.
-	roomUseCase := usecase.NewRoomUseCase(pubSubRepo)
-	messageUseCase := usecase.NewMessageUseCase(messageRepo, messageService)
-
-	// 8. Initialize API router (pass both roomUseCase and messageUseCase).
-	router := api.NewRouter(roomUseCase, messageUseCase)
-
-	// 9. Start HTTP server.
+	// 4. Initialize use cases; presence heartbeats, reaping, typing expiry, session refreshes and
+	// mention fan-out run until shutdown.
+	presenceUseCase := usecase.NewPresenceUseCase(b.presence, pubSubRepo, cfg.NodeID, cfg.PresenceTTL, cfg.PresenceHeartbeat)
+	presenceCtx, stopPresence := context.WithCancel(context.Background())
+	defer stopPresence()
+	go presenceUseCase.Run(presenceCtx)
+	roomAccessUseCase := usecase.NewRoomAccessUseCase(b.rooms, b.sanctions, pubSubRepo)
+	moderationUseCase := usecase.NewModerationUseCase(roomAccessUseCase, b.rooms, b.sanctions, pubSubRepo)
+	roomUseCase := usecase.NewRoomUseCase(pubSubRepo, presenceUseCase, roomAccessUseCase)
+	typingUseCase := usecase.NewTypingUseCase(pubSubRepo, roomUseCase, roomAccessUseCase, cfg.TypingTTL)
+	go typingUseCase.Run(presenceCtx)
+	sessionUseCase := usecase.NewSessionUseCase(b.sessions, roomUseCase, presenceUseCase, pubSubRepo, cfg.SessionResumeWindow)
+	go sessionUseCase.Run(presenceCtx)
+	inboxUseCase := usecase.NewInboxUseCase(b.inboxStore, b.inbox, presenceUseCase, cfg.InboxTTL)
+	go inboxUseCase.Run(presenceCtx)
+	messageUseCase := usecase.NewMessageUseCase(messageRepo, messageService, roomAccessUseCase, inboxUseCase)
+	receiptUseCase := usecase.NewReceiptUseCase(b.receipts, b.receiptCache, messageRepo, b.rooms, roomAccessUseCase, pubSubRepo)
+	mentionUseCase := usecase.NewMentionUseCase(b.mentions, messageRepo, b.rooms, roomAccessUseCase, presenceUseCase, inboxUseCase, pubSubRepo)
+	go mentionUseCase.Run(presenceCtx)
+	rateLimitUseCase := usecase.NewRateLimitUseCase(b.limiter, usecase.RateLimits{
+		User:         redis.RateLimit{Burst: cfg.RateLimitUserBurst, Rate: cfg.RateLimitUserRate},
+		IP:           redis.RateLimit{Burst: cfg.RateLimitIPBurst, Rate: cfg.RateLimitIPRate},
+		Room:         redis.RateLimit{Burst: cfg.RateLimitRoomBurst, Rate: cfg.RateLimitRoomRate},
+		Typing:       redis.RateLimit{Burst: cfg.RateLimitTypingBurst, Rate: cfg.RateLimitTypingRate},
+		MaxStrikes:   cfg.RateLimitStrikes,
+		StrikeWindow: cfg.RateLimitStrikeWindow,
+	})
+
+	// 5. Initialize token verification and the API router.
+	var verifier *auth.Verifier
+	if cfg.JWTSecret != "" || cfg.JWTJWKSFile != "" {
+		v, err := auth.NewVerifier(cfg.JWTSecret, cfg.JWTJWKSFile, cfg.JWTIssuer, cfg.JWTAudience)
+		if err != nil {
+			log.Fatalf("Failed to initialize token verifier: %v", err)
+		}
+		verifier = v
+	} else if cfg.AuthDisabled || cfg.Backend == "memory" {
+		log.Println("WARNING: authentication is disabled; trusting the sender_id query parameter.")
+	} else {
+		log.Fatal("JWT_SECRET or JWT_JWKS_FILE must be set; set AUTH_DISABLED=true to trust sender_id for local development.")
+	}
+	router := api.NewRouter(cfg, verifier, api.UseCases{
+		Room:       roomUseCase,
+		Message:    messageUseCase,
+		Presence:   presenceUseCase,
+		Access:     roomAccessUseCase,
+		Moderation: moderationUseCase,
+		RateLimit:  rateLimitUseCase,
+		Typing:     typingUseCase,
+		Receipt:    receiptUseCase,
+		Mention:    mentionUseCase,
+		Inbox:      inboxUseCase,
+		Session:    sessionUseCase,
+	})
+
+	// 6. Start HTTP server.
 	server := &http.Server{
 		Addr:    ":" + cfg.Port,
 		Handler: router,
@@ -62,7 +101,7 @@ func main() {
 		}
 	}()
 
-	// 10. Graceful shutdown.
+	// 7. Graceful shutdown.
 	gracefulShutdown(server)
 }
 
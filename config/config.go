@@ -5,10 +5,16 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"chat-websocket/pkg/netutil"
 )
 
 // Config holds application configuration values.
 type Config struct {
+	Backend    string // "standard" (MySQL and Redis) or "memory" (in-process, single node).
+	NodeID     string
 	Port       string
 	DBHost     string
 	DBPort     string
@@ -19,11 +25,66 @@ type Config struct {
 	RedisAddr string
 	RedisPass string
 	RedisDB   int
+
+	PubSubBackend string // "pubsub" (Redis Pub/Sub) or "streams" (Redis Streams).
+	StreamMaxLen  int
+
+	SendQueueSize   int
+	SendQueuePolicy string
+
+	PingInterval time.Duration
+	PongWait     time.Duration
+	WriteWait    time.Duration
+
+	PresenceTTL       time.Duration
+	PresenceHeartbeat time.Duration
+
+	// TypingTTL is how long a typing indicator lasts unless the client refreshes it.
+	TypingTTL time.Duration
+
+	// ReceiptCacheTTL is how long a user's cached read watermarks are kept without writes.
+	ReceiptCacheTTL time.Duration
+
+	// Offline inbox: entries kept in Redis per user before older ones spill to MySQL, and how
+	// long each entry is kept after it is queued, in either store.
+	InboxCap int
+	InboxTTL time.Duration
+
+	// SessionResumeWindow is how long after a connection closes its session can be resumed.
+	SessionResumeWindow time.Duration
+
+	// Token buckets for inbound frames: burst size and refill rate (per second) per user, IP and room.
+	RateLimitUserBurst int
+	RateLimitUserRate  float64
+	RateLimitIPBurst   int
+	RateLimitIPRate    float64
+	RateLimitRoomBurst int
+	RateLimitRoomRate  float64
+	// Typing starts are drawn from a local bucket per connection instead.
+	RateLimitTypingBurst int
+	RateLimitTypingRate  float64
+	// A connection throttled RateLimitStrikes times within RateLimitStrikeWindow is disconnected.
+	RateLimitStrikes      int
+	RateLimitStrikeWindow time.Duration
+
+	JWTSecret   string
+	JWTJWKSFile string
+	JWTIssuer   string
+	JWTAudience string
+	// AuthDisabled allows running without JWT verification, trusting the sender_id query
+	// parameter. It is implied by BACKEND=memory and meant for local development only.
+	AuthDisabled bool
+
+	// TrustedProxies are the reverse proxies whose Forwarded and X-Forwarded-For headers
+	// identify the real client IP. Requests from anywhere else are taken at face value.
+	TrustedProxies netutil.TrustedProxies
 }
 
 // LoadConfig reads environment variables and returns a Config struct.
 func LoadConfig() *Config {
 	cfg := &Config{
+		Backend:    getEnv("BACKEND", "standard"),
+		NodeID:     getEnv("NODE_ID", defaultNodeID()),
 		Port:       getEnv("PORT", "8080"),
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "3306"),
@@ -34,8 +95,65 @@ func LoadConfig() *Config {
 		RedisAddr: getEnv("REDIS_ADDR", "localhost:6379"),
 		RedisPass: getEnv("REDIS_PASSWORD", ""),
 		RedisDB:   getEnvAsInt("REDIS_DB", 0),
+
+		PubSubBackend: getEnv("PUBSUB_BACKEND", "pubsub"),
+		StreamMaxLen:  getEnvAsInt("STREAM_MAXLEN", 10000),
+
+		SendQueueSize:   getEnvAsInt("SEND_QUEUE_SIZE", 256),
+		SendQueuePolicy: getEnv("SEND_QUEUE_POLICY", "drop_oldest"),
+
+		PingInterval: getEnvAsDuration("PING_INTERVAL", 54*time.Second),
+		PongWait:     getEnvAsDuration("PONG_WAIT", 60*time.Second),
+		WriteWait:    getEnvAsDuration("WRITE_WAIT", 10*time.Second),
+
+		PresenceTTL:       getEnvAsDuration("PRESENCE_TTL", 30*time.Second),
+		PresenceHeartbeat: getEnvAsDuration("PRESENCE_HEARTBEAT", 10*time.Second),
+
+		TypingTTL: getEnvAsDuration("TYPING_TTL", 5*time.Second),
+
+		ReceiptCacheTTL: getEnvAsDuration("RECEIPT_CACHE_TTL", 24*time.Hour),
+
+		InboxCap: getEnvAsInt("INBOX_CAP", 100),
+		InboxTTL: getEnvAsDuration("INBOX_TTL", 7*24*time.Hour),
+
+		SessionResumeWindow: getEnvAsDuration("SESSION_RESUME_WINDOW", 2*time.Minute),
+
+		RateLimitUserBurst:    getEnvAsInt("RATE_LIMIT_USER_BURST", 20),
+		RateLimitUserRate:     getEnvAsFloat("RATE_LIMIT_USER_RATE", 5),
+		RateLimitIPBurst:      getEnvAsInt("RATE_LIMIT_IP_BURST", 50),
+		RateLimitIPRate:       getEnvAsFloat("RATE_LIMIT_IP_RATE", 20),
+		RateLimitRoomBurst:    getEnvAsInt("RATE_LIMIT_ROOM_BURST", 100),
+		RateLimitRoomRate:     getEnvAsFloat("RATE_LIMIT_ROOM_RATE", 50),
+		RateLimitTypingBurst:  getEnvAsInt("RATE_LIMIT_TYPING_BURST", 20),
+		RateLimitTypingRate:   getEnvAsFloat("RATE_LIMIT_TYPING_RATE", 10),
+		RateLimitStrikes:      getEnvAsInt("RATE_LIMIT_STRIKES", 10),
+		RateLimitStrikeWindow: getEnvAsDuration("RATE_LIMIT_STRIKE_WINDOW", time.Minute),
+
+		JWTSecret:   getEnv("JWT_SECRET", ""),
+		JWTJWKSFile: getEnv("JWT_JWKS_FILE", ""),
+		JWTIssuer:   getEnv("JWT_ISSUER", ""),
+		JWTAudience: getEnv("JWT_AUDIENCE", ""),
+
+		AuthDisabled: getEnvAsBool("AUTH_DISABLED", false),
+	}
+	proxies, err := netutil.ParseTrustedProxies(getEnvAsList("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("[CONFIG] TRUSTED_PROXIES: %v", err)
+	}
+	cfg.TrustedProxies = proxies
+	if cfg.PingInterval >= cfg.PongWait {
+		log.Printf("[CONFIG] PING_INTERVAL %s must be shorter than PONG_WAIT %s; using %s", cfg.PingInterval, cfg.PongWait, cfg.PongWait*9/10)
+		cfg.PingInterval = cfg.PongWait * 9 / 10
+	}
+	if cfg.PresenceHeartbeat >= cfg.PresenceTTL {
+		log.Printf("[CONFIG] PRESENCE_HEARTBEAT %s must be shorter than PRESENCE_TTL %s; using %s", cfg.PresenceHeartbeat, cfg.PresenceTTL, cfg.PresenceTTL/3)
+		cfg.PresenceHeartbeat = cfg.PresenceTTL / 3
 	}
-	log.Printf("[CONFIG] Loaded: %+v\n", cfg)
+	redacted := *cfg
+	if redacted.JWTSecret != "" {
+		redacted.JWTSecret = "***"
+	}
+	log.Printf("[CONFIG] Loaded: %+v\n", redacted)
 	return cfg
 }
 
@@ -56,3 +174,49 @@ func getEnvAsInt(key string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+// getEnvAsFloat retrieves the float value of the environment variable or returns defaultVal if not set/invalid.
+func getEnvAsFloat(key string, defaultVal float64) float64 {
+	if val := os.Getenv(key); val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	}
+	return defaultVal
+}
+
+// getEnvAsBool retrieves the boolean value of the environment variable or returns defaultVal if not set/invalid.
+func getEnvAsBool(key string, defaultVal bool) bool {
+	if val := os.Getenv(key); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	}
+	return defaultVal
+}
+
+// getEnvAsList retrieves a comma-separated list from the environment variable, or nil if not set.
+func getEnvAsList(key string) []string {
+	if val := os.Getenv(key); val != "" {
+		return strings.Split(val, ",")
+	}
+	return nil
+}
+
+// getEnvAsDuration retrieves a duration (e.g. "30s") from the environment variable or returns defaultVal if not set/invalid.
+func getEnvAsDuration(key string, defaultVal time.Duration) time.Duration {
+	if val := os.Getenv(key); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultVal
+}
+
+// defaultNodeID identifies this server instance by hostname, falling back to the process ID.
+func defaultNodeID() string {
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "node-" + strconv.Itoa(os.Getpid())
+}
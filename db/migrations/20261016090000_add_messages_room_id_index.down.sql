@@ -0,0 +1 @@
+ALTER TABLE messages DROP INDEX idx_room_id_id;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE messages ADD INDEX idx_room_id_id (room_id, id);
\ No newline at end of file
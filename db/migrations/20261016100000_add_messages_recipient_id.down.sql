@@ -0,0 +1,3 @@
+ALTER TABLE messages
+    DROP INDEX idx_conversation,
+    DROP COLUMN recipient_id;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE messages
+    ADD COLUMN recipient_id VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'Recipient user ID for direct messages; empty for room messages' AFTER room_id,
+    ADD INDEX idx_conversation (sender_id, recipient_id, id);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS room_invitations;
+DROP TABLE IF EXISTS room_members;
+DROP TABLE IF EXISTS rooms;
\ No newline at end of file
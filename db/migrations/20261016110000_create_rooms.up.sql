@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS rooms (
+    id VARCHAR(255) NOT NULL COMMENT 'Room ID, as used in room_id',
+    visibility VARCHAR(20) NOT NULL DEFAULT 'public' COMMENT 'public, invite_only or private',
+    owner_id VARCHAR(255) NOT NULL COMMENT 'User who created the room',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp when the room was created',
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS room_members (
+    room_id VARCHAR(255) NOT NULL COMMENT 'Room ID',
+    user_id VARCHAR(255) NOT NULL COMMENT 'Member user ID',
+    role VARCHAR(20) NOT NULL DEFAULT 'member' COMMENT 'owner, admin or member',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp when the user became a member',
+    PRIMARY KEY (room_id, user_id),
+    KEY idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS room_invitations (
+    id BIGINT AUTO_INCREMENT NOT NULL COMMENT 'Invitation ID, primary key',
+    room_id VARCHAR(255) NOT NULL COMMENT 'Room the invitee is invited into',
+    inviter_id VARCHAR(255) NOT NULL COMMENT 'User who sent the invitation',
+    invitee_id VARCHAR(255) NOT NULL COMMENT 'Invited user',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' COMMENT 'pending or accepted',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp when the invitation was sent',
+    accepted_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when the invitation was accepted',
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_room_invitee (room_id, invitee_id),
+    KEY idx_invitee_id (invitee_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS room_sanctions (
+    id BIGINT AUTO_INCREMENT NOT NULL COMMENT 'Sanction ID, primary key',
+    room_id VARCHAR(255) NOT NULL COMMENT 'Room the sanction applies to',
+    user_id VARCHAR(255) NOT NULL COMMENT 'Sanctioned user',
+    kind VARCHAR(20) NOT NULL COMMENT 'ban or mute',
+    moderator_id VARCHAR(255) NOT NULL COMMENT 'User who imposed the sanction',
+    reason VARCHAR(500) NOT NULL DEFAULT '' COMMENT 'Reason given by the moderator',
+    expires_at TIMESTAMP NULL DEFAULT NULL COMMENT 'When the sanction lapses; NULL for indefinitely',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp when the sanction was imposed',
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_room_user_kind (room_id, user_id, kind)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
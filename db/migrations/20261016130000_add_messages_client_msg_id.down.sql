@@ -0,0 +1,3 @@
+ALTER TABLE messages
+    DROP INDEX idx_sender_client_msg,
+    DROP COLUMN client_msg_id;
\ No newline at end of file
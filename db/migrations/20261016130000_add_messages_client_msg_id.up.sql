@@ -0,0 +1,3 @@
+ALTER TABLE messages
+    ADD COLUMN client_msg_id VARCHAR(64) NULL DEFAULT NULL COMMENT 'Sender-generated idempotency key' AFTER content,
+    ADD UNIQUE INDEX idx_sender_client_msg (sender_id, client_msg_id);
\ No newline at end of file
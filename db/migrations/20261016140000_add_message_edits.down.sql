@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS message_edits;
+
+ALTER TABLE messages
+    DROP COLUMN deleted_at,
+    DROP COLUMN edited_at;
\ No newline at end of file
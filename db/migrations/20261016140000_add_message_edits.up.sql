@@ -0,0 +1,13 @@
+ALTER TABLE messages
+    ADD COLUMN edited_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp of the last edit' AFTER created_at,
+    ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp of the soft delete' AFTER edited_at;
+
+CREATE TABLE IF NOT EXISTS message_edits (
+    id BIGINT AUTO_INCREMENT NOT NULL COMMENT 'Edit ID, primary key',
+    message_id BIGINT NOT NULL COMMENT 'Edited message',
+    editor_id VARCHAR(255) NOT NULL COMMENT 'User who made the edit',
+    previous_content TEXT NOT NULL COMMENT 'Content before the edit',
+    edited_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp of the edit',
+    PRIMARY KEY (id),
+    KEY idx_message_id (message_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
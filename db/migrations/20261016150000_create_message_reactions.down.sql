@@ -0,0 +1 @@
+DROP TABLE IF EXISTS message_reactions;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS message_reactions (
+    id BIGINT AUTO_INCREMENT NOT NULL COMMENT 'Reaction ID, primary key',
+    message_id BIGINT NOT NULL COMMENT 'Message reacted to',
+    user_id VARCHAR(255) NOT NULL COMMENT 'User who reacted',
+    emoji VARCHAR(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT 'Reaction emoji, compared byte for byte',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp of the reaction',
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_message_user_emoji (message_id, user_id, emoji),
+    KEY idx_message_emoji (message_id, emoji)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
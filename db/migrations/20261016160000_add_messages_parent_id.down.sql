@@ -0,0 +1,5 @@
+ALTER TABLE messages
+    DROP INDEX idx_parent_id,
+    DROP COLUMN last_reply_at,
+    DROP COLUMN reply_count,
+    DROP COLUMN parent_id;
\ No newline at end of file
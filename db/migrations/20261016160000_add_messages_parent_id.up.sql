@@ -0,0 +1,5 @@
+ALTER TABLE messages
+    ADD COLUMN parent_id BIGINT NULL DEFAULT NULL COMMENT 'Thread parent, NULL for top-level messages' AFTER recipient_id,
+    ADD COLUMN reply_count INT NOT NULL DEFAULT 0 COMMENT 'Number of replies in the thread started by this message' AFTER deleted_at,
+    ADD COLUMN last_reply_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp of the newest reply' AFTER reply_count,
+    ADD INDEX idx_parent_id (parent_id, id);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS room_read_receipts;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS room_read_receipts (
+    room_id VARCHAR(255) NOT NULL COMMENT 'Room the watermark belongs to',
+    user_id VARCHAR(255) NOT NULL COMMENT 'Reader',
+    last_read_id BIGINT NOT NULL DEFAULT 0 COMMENT 'Newest message read; only ever increases',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Timestamp of the last advance',
+    PRIMARY KEY (room_id, user_id),
+    KEY idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS message_mentions (
+    id BIGINT AUTO_INCREMENT NOT NULL COMMENT 'Mention ID, primary key',
+    message_id BIGINT NOT NULL COMMENT 'Mentioning message',
+    room_id VARCHAR(255) NOT NULL COMMENT 'Room of the message',
+    user_id VARCHAR(255) NOT NULL COMMENT 'Mentioned user',
+    sender_id VARCHAR(255) NOT NULL COMMENT 'Author of the message',
+    kind VARCHAR(20) NOT NULL DEFAULT 'user' COMMENT 'user, room or here',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp of the mention',
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_message_user (message_id, user_id),
+    KEY idx_user_id_id (user_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
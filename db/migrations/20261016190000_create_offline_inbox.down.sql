@@ -0,0 +1 @@
+DROP TABLE IF EXISTS offline_inbox;
\ No newline at end of file
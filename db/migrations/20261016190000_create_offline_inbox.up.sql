@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS offline_inbox (
+    user_id VARCHAR(255) NOT NULL COMMENT 'Recipient',
+    id BIGINT NOT NULL COMMENT 'Per-user inbox sequence number',
+    event MEDIUMTEXT NOT NULL COMMENT 'Event JSON as it is delivered',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp when the event was queued',
+    PRIMARY KEY (user_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
@@ -0,0 +1,5 @@
+DELETE room_members FROM room_members
+JOIN rooms ON rooms.id = room_members.room_id
+WHERE rooms.owner_id = '' AND room_members.role = 'member';
+
+DELETE FROM rooms WHERE owner_id = '';
\ No newline at end of file
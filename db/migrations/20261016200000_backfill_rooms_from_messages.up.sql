@@ -0,0 +1,11 @@
+INSERT IGNORE INTO rooms (id, visibility, owner_id, created_at)
+SELECT room_id, 'public', '', MIN(created_at)
+FROM messages
+WHERE room_id <> ''
+GROUP BY room_id;
+
+INSERT IGNORE INTO room_members (room_id, user_id, role, created_at)
+SELECT room_id, sender_id, 'member', MIN(created_at)
+FROM messages
+WHERE room_id <> ''
+GROUP BY room_id, sender_id;
\ No newline at end of file
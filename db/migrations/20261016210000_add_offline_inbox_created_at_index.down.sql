@@ -0,0 +1 @@
+ALTER TABLE offline_inbox DROP INDEX idx_created_at;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE offline_inbox ADD INDEX idx_created_at (created_at);
\ No newline at end of file
@@ -22,7 +22,8 @@ func InitMySQL(cfg *config.Config) *gorm.DB {
 		cfg.DBName,
 	)
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	// TranslateError maps duplicate-key violations to gorm.ErrDuplicatedKey.
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		log.Printf("Failed to connect to MySQL: %v", err)
 		return nil
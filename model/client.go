@@ -2,21 +2,346 @@
 package model
 
 import (
+	"log"
 	"sync"
+	"time"
+
+	"chat-websocket/pkg/metrics"
 
 	"github.com/gorilla/websocket"
 )
 
+// OverflowPolicy decides what happens when a client's send queue is full.
+type OverflowPolicy string
+
+const (
+	DropOldest OverflowPolicy = "drop_oldest" // Discard the oldest queued frame to make room.
+	DropNewest OverflowPolicy = "drop_newest" // Discard the frame being sent.
+	Disconnect OverflowPolicy = "disconnect"  // Close the connection with code 1013 (try again later).
+)
+
+// CloseSessionRevoked is the close code of a connection its user revoked from another device.
+const CloseSessionRevoked = 4001
+
+// ClientOptions configures a client's outbound write pump.
+type ClientOptions struct {
+	QueueSize    int            // Capacity of the send queue.
+	Policy       OverflowPolicy // Behaviour when the send queue is full.
+	WriteWait    time.Duration  // Deadline for a single write to the socket.
+	PingInterval time.Duration  // Interval between keepalive pings.
+}
+
 // Client represents a connected user's WebSocket session along with optional user data.
 type Client struct {
-	ID    string          // Unique identifier (e.g., remote address)
+	ID    string          // Connection ID, unique across the cluster (node ID and a random suffix).
 	Conn  *websocket.Conn // WebSocket connection
-	Mutex sync.Mutex      // To protect write operations
+	Mutex sync.Mutex      // Protects the send queue state.
+
+	// IP is the client's real IP, as forwarded by trusted proxies; UserAgent describes the device.
+	IP        string
+	UserAgent string
 
 	// Optional database fields:
 	ClientID string
 	Email    string
 	Name     string
 	Status   int
-	SenderID string
+	SenderID string // The authenticated user ID; a user may have several connections.
+
+	opts      ClientOptions
+	send      chan []byte
+	done      chan struct{}
+	closed    bool
+	closeCode int
+	closeText string
+
+	awaitingPong bool // A ping was sent and no pong has arrived yet.
+	degraded     bool // A ping interval passed without a pong.
+	lossless     bool // A full send queue disconnects rather than drops; see SetLossless.
+
+	held map[string][]heldEvent // Live events per room held back during a history replay.
+}
+
+// heldEvent is a live event queued while its room's history is being replayed.
+type heldEvent struct {
+	eventType string
+	id        int64
+	data      []byte
+}
+
+// NewClient creates a Client whose outbound frames are written by a single write pump.
+// The caller must start WritePump in its own goroutine.
+func NewClient(id string, conn *websocket.Conn, opts ClientOptions) *Client {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 256
+	}
+	switch opts.Policy {
+	case DropOldest, DropNewest, Disconnect:
+	default:
+		opts.Policy = DropOldest
+	}
+	if opts.WriteWait <= 0 {
+		opts.WriteWait = 10 * time.Second
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = 54 * time.Second
+	}
+	return &Client{
+		ID:   id,
+		Conn: conn,
+		opts: opts,
+		send: make(chan []byte, opts.QueueSize),
+		done: make(chan struct{}),
+	}
+}
+
+// Send queues a text frame for the write pump without blocking.
+// It returns false if the frame was dropped or the client is closed.
+func (c *Client) Send(data []byte) bool {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	return c.sendLocked(data)
+}
+
+// sendLocked is Send with c.Mutex held.
+func (c *Client) sendLocked(data []byte) bool {
+	if c.closed {
+		return false
+	}
+
+	select {
+	case c.send <- data:
+		metrics.SendQueueDepth.Inc()
+		return true
+	default:
+	}
+
+	policy := c.opts.Policy
+	if c.lossless {
+		policy = Disconnect
+	}
+	switch policy {
+	case DropNewest:
+		metrics.SendQueueDropped.WithLabelValues(string(DropNewest)).Inc()
+		return false
+	case Disconnect:
+		log.Printf("Send queue full for client %s, disconnecting slow consumer.", c.ID)
+		metrics.SlowConsumerEvictions.Inc()
+		c.closeLocked(websocket.CloseTryAgainLater, "send queue full")
+		return false
+	default:
+		// Only the write pump receives from the queue, so after removing one frame there is room.
+		select {
+		case <-c.send:
+			metrics.SendQueueDepth.Dec()
+			metrics.SendQueueDropped.WithLabelValues(string(DropOldest)).Inc()
+		default:
+		}
+		select {
+		case c.send <- data:
+			metrics.SendQueueDepth.Inc()
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// SendBlocking queues a text frame for the write pump, waiting for room in the send queue
+// rather than applying the overflow policy. It returns false if the client is closed first.
+func (c *Client) SendBlocking(data []byte) bool {
+	c.Mutex.Lock()
+	closed := c.closed
+	c.Mutex.Unlock()
+	if closed {
+		return false
+	}
+
+	select {
+	case c.send <- data:
+		metrics.SendQueueDepth.Inc()
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// SetLossless sets whether a full send queue disconnects the client whatever its overflow policy.
+// It is set while frames that must not be lost are queued, so that they are either written or,
+// after the client reconnects, sent again; dropping older frames could silently lose them.
+func (c *Client) SetLossless(lossless bool) {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	c.lossless = lossless
+}
+
+// SendEvent queues an encoded event, holding it back if a replay is in progress for its room.
+// Held events are never dropped: a client that falls a full queue behind during a replay is
+// disconnected with code 1013, as under the Disconnect policy, and replays again on reconnect.
+func (c *Client) SendEvent(event *Event, data []byte) bool {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	pending, ok := c.held[event.RoomID]
+	if !ok {
+		return c.sendLocked(data)
+	}
+	if c.closed {
+		return false
+	}
+	if len(pending) >= c.opts.QueueSize {
+		log.Printf("Replay buffer full for client %s in room %s, disconnecting slow consumer.", c.ID, event.RoomID)
+		metrics.SlowConsumerEvictions.Inc()
+		delete(c.held, event.RoomID)
+		c.closeLocked(websocket.CloseTryAgainLater, "replay buffer full")
+		return false
+	}
+	c.held[event.RoomID] = append(pending, heldEvent{eventType: event.Type, id: event.ID, data: data})
+	return true
+}
+
+// BeginReplay starts holding live events for roomID so that history can be sent first.
+func (c *Client) BeginReplay(roomID string) {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	if c.held == nil {
+		c.held = make(map[string][]heldEvent)
+	}
+	if _, ok := c.held[roomID]; !ok {
+		c.held[roomID] = nil
+	}
+}
+
+// EndReplay releases the events held for roomID, skipping messages with IDs up to lastID
+// because the replay already delivered them. The held events are queued before the lock is
+// released, so no live event sent concurrently can overtake them.
+func (c *Client) EndReplay(roomID string, lastID int64) {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	pending := c.held[roomID]
+	delete(c.held, roomID)
+
+	for _, ev := range pending {
+		if ev.eventType == EventMessage && ev.id != 0 && ev.id <= lastID {
+			continue
+		}
+		c.sendLocked(ev.data)
+	}
+}
+
+// WritePump writes queued frames and keepalive pings to the connection until the client is closed.
+// It is the only goroutine that writes to Conn.
+func (c *Client) WritePump() {
+	ticker := time.NewTicker(c.opts.PingInterval)
+	defer func() {
+		ticker.Stop()
+		c.shutdown()
+	}()
+
+	for {
+		select {
+		case data := <-c.send:
+			metrics.SendQueueDepth.Dec()
+			_ = c.Conn.SetWriteDeadline(time.Now().Add(c.opts.WriteWait))
+			if err := c.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Printf("Failed to send message to client %s: %v", c.ID, err)
+				c.Close()
+				return
+			}
+		case <-ticker.C:
+			c.notePing()
+			if err := c.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.opts.WriteWait)); err != nil {
+				log.Printf("Failed to ping client %s: %v", c.ID, err)
+				c.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// MarkAlive records a pong from the peer and clears the degraded state.
+func (c *Client) MarkAlive() {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	c.awaitingPong = false
+	c.setDegradedLocked(false)
+}
+
+// notePing records an outgoing ping; a client still owing a pong for the previous one is degraded.
+func (c *Client) notePing() {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	if c.awaitingPong {
+		c.setDegradedLocked(true)
+	}
+	c.awaitingPong = true
+}
+
+// setDegradedLocked updates the degraded flag and gauge; c.Mutex must be held.
+func (c *Client) setDegradedLocked(degraded bool) {
+	if c.degraded == degraded {
+		return
+	}
+	c.degraded = degraded
+	if degraded {
+		metrics.DegradedConnections.Inc()
+	} else {
+		metrics.DegradedConnections.Dec()
+	}
+}
+
+// Close stops the write pump and closes the connection. It is safe to call more than once.
+func (c *Client) Close() {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	c.closeLocked(0, "")
+}
+
+// CloseWith stops the write pump after sending a close frame with the given code and reason.
+func (c *Client) CloseWith(code int, text string) {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	c.closeLocked(code, text)
+}
+
+// CloseCode returns the close code the client was closed with, or 0.
+func (c *Client) CloseCode() int {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	return c.closeCode
+}
+
+// Done returns a channel that is closed once the client has been closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// closeLocked marks the client closed; c.Mutex must be held.
+func (c *Client) closeLocked(code int, text string) {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.closeCode = code
+	c.closeText = text
+	close(c.done)
+}
+
+// shutdown discards unsent frames, sends the pending close frame if any and closes the socket.
+func (c *Client) shutdown() {
+	for len(c.send) > 0 {
+		<-c.send
+		metrics.SendQueueDepth.Dec()
+	}
+
+	c.Mutex.Lock()
+	c.setDegradedLocked(false)
+	c.Mutex.Unlock()
+
+	if c.closeCode != 0 {
+		msg := websocket.FormatCloseMessage(c.closeCode, c.closeText)
+		_ = c.Conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(c.opts.WriteWait))
+	}
+	_ = c.Conn.Close()
 }
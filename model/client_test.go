@@ -0,0 +1,161 @@
+// model/client_test.go
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// queued drains the client's send queue and returns the IDs of the queued events.
+func queued(t *testing.T, c *Client) []int64 {
+	t.Helper()
+	var ids []int64
+	for {
+		select {
+		case data := <-c.send:
+			var event Event
+			if err := json.Unmarshal(data, &event); err != nil {
+				t.Fatalf("decode queued frame: %v", err)
+			}
+			ids = append(ids, event.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+// sendMessage passes a message event with the given ID in roomID to SendEvent.
+func sendMessage(c *Client, roomID string, id int64) bool {
+	event := NewEvent(EventMessage, roomID, "alice")
+	event.ID = id
+	data, _ := json.Marshal(event)
+	return c.SendEvent(event, data)
+}
+
+func TestClientReplayHoldsLiveEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		room   string // Room of the live events sent during the replay of room "lobby".
+		live   []int64
+		lastID int64 // Last ID delivered by the replay.
+		during []int64
+		after  []int64
+	}{
+		{
+			name:   "held until the replay ends",
+			room:   "lobby",
+			live:   []int64{11, 12},
+			lastID: 10,
+			during: nil,
+			after:  []int64{11, 12},
+		},
+		{
+			name:   "messages already replayed are skipped",
+			room:   "lobby",
+			live:   []int64{9, 10, 11},
+			lastID: 10,
+			during: nil,
+			after:  []int64{11},
+		},
+		{
+			name:   "other rooms are not held",
+			room:   "garden",
+			live:   []int64{3, 4},
+			lastID: 10,
+			during: []int64{3, 4},
+			after:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient("alice-1", nil, ClientOptions{})
+			c.BeginReplay("lobby")
+			for _, id := range tt.live {
+				if !sendMessage(c, tt.room, id) {
+					t.Fatalf("event %d was not accepted", id)
+				}
+			}
+			if got := queued(t, c); !equalIDs(got, tt.during) {
+				t.Errorf("queued during the replay = %v, want %v", got, tt.during)
+			}
+
+			c.EndReplay("lobby", tt.lastID)
+			if got := queued(t, c); !equalIDs(got, tt.after) {
+				t.Errorf("queued after the replay = %v, want %v", got, tt.after)
+			}
+		})
+	}
+}
+
+func TestClientEndReplayKeepsOrder(t *testing.T) {
+	const held, live = 1000, 2000
+	for i := 0; i < 20; i++ {
+		c := NewClient("alice-1", nil, ClientOptions{QueueSize: held + live})
+		c.BeginReplay("lobby")
+		for id := int64(1); id < held; id++ {
+			sendMessage(c, "lobby", id)
+		}
+
+		// Live events keep arriving on the dispatcher while the replay ends.
+		started := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for id := int64(held); id <= held+live; id++ {
+				sendMessage(c, "lobby", id)
+				if id == held {
+					close(started)
+				}
+			}
+		}()
+		<-started
+		c.EndReplay("lobby", 0)
+		<-done
+
+		ids := queued(t, c)
+		if len(ids) != held+live {
+			t.Fatalf("queued %d events, want %d", len(ids), held+live)
+		}
+		for j := 1; j < len(ids); j++ {
+			if ids[j] <= ids[j-1] {
+				t.Fatalf("event %d queued after %d", ids[j], ids[j-1])
+			}
+		}
+	}
+}
+
+func TestClientReplayOverflowDisconnects(t *testing.T) {
+	c := NewClient("alice-1", nil, ClientOptions{QueueSize: 4, Policy: DropOldest})
+	c.BeginReplay("lobby")
+	for id := int64(1); id <= 4; id++ {
+		if !sendMessage(c, "lobby", id) {
+			t.Fatalf("event %d was not held", id)
+		}
+	}
+
+	if sendMessage(c, "lobby", 5) {
+		t.Errorf("event beyond the replay buffer was accepted")
+	}
+	if got := c.CloseCode(); got != websocket.CloseTryAgainLater {
+		t.Errorf("close code = %d, want %d", got, websocket.CloseTryAgainLater)
+	}
+	c.EndReplay("lobby", 0)
+	if got := queued(t, c); len(got) != 0 {
+		t.Errorf("closed client queued %v", got)
+	}
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,220 @@
+// model/event.go
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventVersion is the current version of the wire envelope.
+const EventVersion = 1
+
+// Event types carried by the envelope.
+const (
+	EventMessage        = "message"
+	EventDirect         = "dm" // A direct message, delivered to the recipient's connections.
+	EventJoin           = "join"
+	EventLeave          = "leave"
+	EventReplayDone     = "replay_done"     // Sent to a joining client after its history replay.
+	EventPresence       = "presence"        // A user's aggregate status changed.
+	EventInvitation     = "invitation"      // The recipient was invited into a room.
+	EventError          = "error"           // A client request was rejected.
+	EventModeration     = "moderation"      // A moderator kicked, banned, muted or pardoned a user.
+	EventAck            = "ack"             // A message or dm from the client was stored.
+	EventNack           = "nack"            // A message or dm from the client was rejected.
+	EventMessageUpdated = "message_updated" // A message's content was edited.
+	EventMessageDeleted = "message_deleted" // A message was deleted.
+	EventReaction       = "reaction"        // A user added or removed a reaction to a message.
+	EventThreadUpdated  = "thread_updated"  // A reply was posted in the thread of a room message.
+	EventTyping         = "typing"          // A user started or stopped typing in a room.
+	EventReadReceipt    = "read_receipt"    // A user read a room up to a message.
+	EventMentioned      = "mentioned"       // A room message mentioned the recipient.
+	EventInboxDone      = "inbox_done"      // Sent to a connecting client after its offline inbox.
+	EventSession        = "session"         // Sent to a connecting client with its resumable session.
+	EventSessionRevoked = "session_revoked" // A user disconnected one of their devices.
+)
+
+// Error codes carried by error events.
+const (
+	ErrCodeInvalid     = "invalid_request"
+	ErrCodeForbidden   = "forbidden"
+	ErrCodeNotFound    = "not_found"
+	ErrCodeConflict    = "conflict"
+	ErrCodeInternal    = "internal"
+	ErrCodeRateLimited = "rate_limited"
+)
+
+// Event is the versioned envelope published through Pub/Sub and written to WebSocket clients.
+type Event struct {
+	Version   int             `json:"v"`
+	Type      string          `json:"type"`
+	ID        int64           `json:"id,omitempty"`
+	RoomID    string          `json:"room_id,omitempty"`
+	SenderID  string          `json:"sender_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Payload   json.RawMessage `json:"payload,omitempty"` // Event-specific data.
+	Seq       string          `json:"seq,omitempty"`     // Per-room sequence number when the transport provides one.
+	// ClientMsgID echoes the sender's idempotency key on message, dm and ack/nack events.
+	ClientMsgID string `json:"client_msg_id,omitempty"`
+	// EditedAt and DeletedAt mark edited and deleted messages on message, dm and replayed events.
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Reactions are the reaction counts of replayed messages.
+	Reactions []ReactionCount `json:"reactions,omitempty"`
+	// ParentID marks replies; ReplyCount and LastReplyAt summarize a thread on replayed messages
+	// and thread_updated events.
+	ParentID    int64      `json:"parent_id,omitempty"`
+	ReplyCount  int        `json:"reply_count,omitempty"`
+	LastReplyAt *time.Time `json:"last_reply_at,omitempty"`
+	// InboxID marks events delivered from the offline inbox; clients acknowledge it with inbox_ack.
+	InboxID int64 `json:"inbox_id,omitempty"`
+}
+
+// MembershipPayload is the payload of join and leave events.
+type MembershipPayload struct {
+	ClientID string `json:"client_id"`
+}
+
+// DirectPayload is the payload of dm events.
+type DirectPayload struct {
+	RecipientID string `json:"recipient_id"`
+}
+
+// PresencePayload is the payload of presence events.
+type PresencePayload struct {
+	UserID string `json:"user_id"`
+	Status string `json:"status"` // online, away or offline.
+}
+
+// InvitationPayload is the payload of invitation events.
+type InvitationPayload struct {
+	InviterID string `json:"inviter_id"`
+}
+
+// ChangePayload is the payload of message_updated and message_deleted events.
+type ChangePayload struct {
+	ChangedBy string    `json:"changed_by"` // The author, or the moderator who deleted the message.
+	ChangedAt time.Time `json:"changed_at"`
+	// RecipientID is set when the changed message is a direct message.
+	RecipientID string `json:"recipient_id,omitempty"`
+}
+
+// ReactionPayload is the payload of reaction events; the event's ID is that of the message.
+type ReactionPayload struct {
+	UserID string `json:"user_id"`
+	Emoji  string `json:"emoji"`
+	Delta  int    `json:"delta"` // 1 when the reaction was added, -1 when it was removed.
+	Count  int    `json:"count"` // The number of users now reacting with Emoji.
+	// RecipientID is set when the message is a direct message.
+	RecipientID string `json:"recipient_id,omitempty"`
+}
+
+// TypingPayload is the payload of typing events. Clients should clear an indicator that is not
+// refreshed within ExpiresInMs even if no stop arrives.
+type TypingPayload struct {
+	Typing      bool  `json:"typing"`
+	ExpiresInMs int64 `json:"expires_in_ms,omitempty"`
+}
+
+// ReceiptPayload is the payload of read_receipt events.
+type ReceiptPayload struct {
+	UserID     string `json:"user_id"`
+	LastReadID int64  `json:"last_read_id"`
+}
+
+// MentionPayload is the payload of mentioned events, which otherwise carry the mentioning message.
+type MentionPayload struct {
+	Kind string `json:"kind"` // user, room or here.
+}
+
+// AckPayload is the payload of ack events; the event's ID and created_at are those of the stored message.
+type AckPayload struct {
+	Duplicate bool `json:"duplicate,omitempty"` // The message had already been stored by an earlier attempt.
+}
+
+// ModerationPayload is the payload of moderation events.
+type ModerationPayload struct {
+	Action    string     `json:"action"` // kick, ban, mute, unban or unmute.
+	UserID    string     `json:"user_id"`
+	Reason    string     `json:"reason,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Unset for kicks, pardons and permanent sanctions.
+}
+
+// ErrorPayload is the payload of error and nack events.
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Action  string `json:"action,omitempty"` // The rejected action.
+	// RetryAfterMs is set on rate_limited errors: how long until the client may send again.
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+// ReplayPayload is the payload of replay_done events.
+type ReplayPayload struct {
+	Count     int   `json:"count"`
+	LastID    int64 `json:"last_id,omitempty"`
+	Truncated bool  `json:"truncated,omitempty"` // The client was too far behind; only the newest messages were replayed.
+}
+
+// InboxPayload is the payload of inbox_done events.
+type InboxPayload struct {
+	Count  int   `json:"count"`
+	LastID int64 `json:"last_id,omitempty"` // The inbox ID to acknowledge to clear everything delivered.
+	More   bool  `json:"more,omitempty"`    // Another page follows once LastID is acknowledged.
+}
+
+// SessionPayload is the payload of session events.
+type SessionPayload struct {
+	Token          string `json:"token"` // Pass as the resume query parameter when reconnecting.
+	ResumeWindowMs int64  `json:"resume_window_ms"`
+	Resumed        bool   `json:"resumed,omitempty"` // The rooms and threads of a previous session are being restored.
+}
+
+// RevokePayload is the payload of session_revoked events.
+type RevokePayload struct {
+	ConnID string `json:"conn_id"`
+}
+
+// NewEvent creates an event of the given type stamped with the current time.
+func NewEvent(eventType, roomID, senderID string) *Event {
+	return &Event{
+		Version:   EventVersion,
+		Type:      eventType,
+		RoomID:    roomID,
+		SenderID:  senderID,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// NewMessageEvent builds a chat message or direct message event from a stored message.
+func NewMessageEvent(msg *Message) *Event {
+	ev := NewEvent(EventMessage, msg.RoomID, msg.SenderID)
+	ev.ID = msg.ID
+	ev.Content = msg.Content
+	ev.ClientMsgID = msg.ClientMsgID
+	ev.EditedAt = msg.EditedAt
+	ev.DeletedAt = msg.DeletedAt
+	ev.Reactions = msg.Reactions
+	ev.ParentID = msg.ParentID
+	ev.ReplyCount = msg.ReplyCount
+	ev.LastReplyAt = msg.LastReplyAt
+	if !msg.CreatedAt.IsZero() {
+		ev.CreatedAt = msg.CreatedAt.UTC()
+	}
+	if msg.RecipientID != "" {
+		ev.Type = EventDirect
+		_ = ev.SetPayload(DirectPayload{RecipientID: msg.RecipientID})
+	}
+	return ev
+}
+
+// SetPayload marshals v into the event payload.
+func (e *Event) SetPayload(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.Payload = data
+	return nil
+}
@@ -0,0 +1,19 @@
+// model/inbox.go
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// InboxEntry is an event kept for a user who was offline when it was sent. IDs increase per user
+// and are acknowledged cumulatively by the client.
+type InboxEntry struct {
+	ID        int64           `json:"id" gorm:"primaryKey;autoIncrement:false"`
+	UserID    string          `json:"user_id" gorm:"primaryKey"`
+	Event     json.RawMessage `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// TableName maps InboxEntry to the offline_inbox table.
+func (InboxEntry) TableName() string { return "offline_inbox" }
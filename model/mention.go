@@ -0,0 +1,58 @@
+// model/mention.go
+package model
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Mention kinds.
+const (
+	MentionUser = "user" // @user_id names the user.
+	MentionRoom = "room" // @room notifies every member of the room.
+	MentionHere = "here" // @here notifies the members currently present in the room.
+)
+
+// Mention records that a message mentioned a user, directly or through @room or @here.
+type Mention struct {
+	ID        int64     `json:"id"`
+	MessageID int64     `json:"message_id"`
+	RoomID    string    `json:"room_id"`
+	UserID    string    `json:"user_id"`
+	SenderID  string    `json:"sender_id"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Message is the mentioning message, filled in when mentions are listed.
+	Message *Message `json:"message,omitempty" gorm:"-"`
+}
+
+// TableName maps Mention to the message_mentions table.
+func (Mention) TableName() string { return "message_mentions" }
+
+// mentionPattern matches @tokens that start a word; the token may contain dots and dashes inside.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w][\w.\-]*)`)
+
+// ParseMentions returns the distinct user IDs mentioned in content, in order of appearance,
+// and whether it mentions @room or @here.
+func ParseMentions(content string) (users []string, room, here bool) {
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		token := strings.TrimRight(match[1], ".-")
+		switch token {
+		case "":
+			continue
+		case MentionRoom:
+			room = true
+		case MentionHere:
+			here = true
+		default:
+			if !seen[token] {
+				seen[token] = true
+				users = append(users, token)
+			}
+		}
+	}
+	return users, room, here
+}
@@ -4,12 +4,79 @@ import "time"
 
 // Message represents a chat message structure.
 type Message struct {
-	ID        int64     `json:"id,omitempty"`
-	SenderID  string    `json:"sender_id,omitempty"`
-	RoomID    string    `json:"room_id,omitempty"`
+	ID       int64  `json:"id,omitempty"`
+	SenderID string `json:"sender_id,omitempty"`
+	RoomID   string `json:"room_id,omitempty"`
+	// RecipientID is set for direct messages, which have no room.
+	RecipientID string `json:"recipient_id,omitempty"`
+	// ParentID is set for replies and names the top-level room message that started the thread.
+	ParentID  int64     `json:"parent_id,omitempty" gorm:"default:null"`
 	Content   string    `json:"content,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
+	// ClientMsgID is the sender's idempotency key; a resent message with the same key is stored once.
+	ClientMsgID string `json:"client_msg_id,omitempty" gorm:"default:null"`
+	// EditedAt is set once the author edits the message; DeletedAt marks a soft-deleted message,
+	// whose content is no longer served.
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// ReplyCount and LastReplyAt summarize the thread started by a top-level message.
+	ReplyCount  int        `json:"reply_count,omitempty"`
+	LastReplyAt *time.Time `json:"last_reply_at,omitempty"`
+	// Reactions are the message's reaction counts, filled in by history queries.
+	Reactions []ReactionCount `json:"reactions,omitempty" gorm:"-"`
 
-	// For WebSocket actions:
-	Action string `json:"action,omitempty"` // join, leave, message
+	// For WebSocket actions: join, leave, message, dm, status, create, invite, accept,
+	// set_role, kick, ban, unban, mute, unmute, edit, delete, react, unreact,
+	// subscribe_thread, unsubscribe_thread, typing_start, typing_stop, read, inbox_ack, ack.
+	Action string `json:"action,omitempty"`
+
+	// Join options, not persisted: replay messages after SinceID, or the last LastN messages.
+	SinceID int64 `json:"since_id,omitempty" gorm:"-"`
+	LastN   int   `json:"last_n,omitempty" gorm:"-"`
+
+	// Status option, not persisted: the connection's presence status (online or away).
+	Status string `json:"status,omitempty" gorm:"-"`
+
+	// Room management options, not persisted: the user acted on, the room visibility on create,
+	// and the role to grant with set_role.
+	TargetID   string `json:"user_id,omitempty" gorm:"-"`
+	Visibility string `json:"visibility,omitempty" gorm:"-"`
+	Role       string `json:"role,omitempty" gorm:"-"`
+
+	// Moderation options, not persisted: how long a ban or mute lasts in seconds (0 means
+	// indefinitely) and why it was imposed.
+	Duration int64  `json:"duration,omitempty" gorm:"-"`
+	Reason   string `json:"reason,omitempty" gorm:"-"`
+
+	// Read option, not persisted: the newest message of the room the user has read.
+	ReadUpTo int64 `json:"read_up_to,omitempty" gorm:"-"`
+
+	// Session acknowledgement option, not persisted: the newest message of the room the client
+	// has received, from which a resumed session replays.
+	LastID int64 `json:"last_id,omitempty" gorm:"-"`
+
+	// Inbox acknowledgement option, not persisted: the last offline inbox entry the client received.
+	InboxID int64 `json:"inbox_id,omitempty" gorm:"-"`
+
+	// Edit, delete, reaction and thread subscription options, not persisted: the message acted
+	// on and the emoji to add or remove.
+	MessageID int64  `json:"message_id,omitempty" gorm:"-"`
+	Emoji     string `json:"emoji,omitempty" gorm:"-"`
+}
+
+// Redact clears the content and reactions of a deleted message so that only a tombstone is served.
+func (m *Message) Redact() {
+	if m.DeletedAt != nil {
+		m.Content = ""
+		m.Reactions = nil
+	}
+}
+
+// MessageEdit records a message's content before one of its edits.
+type MessageEdit struct {
+	ID              int64     `json:"id"`
+	MessageID       int64     `json:"message_id"`
+	EditorID        string    `json:"editor_id"`
+	PreviousContent string    `json:"previous_content"`
+	EditedAt        time.Time `json:"edited_at"`
 }
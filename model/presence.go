@@ -0,0 +1,45 @@
+// model/presence.go
+package model
+
+import "time"
+
+// Presence statuses.
+const (
+	StatusOnline  = "online"
+	StatusAway    = "away"
+	StatusOffline = "offline"
+)
+
+// Presence describes one live connection of a user somewhere in the cluster.
+type Presence struct {
+	UserID      string    `json:"user_id"`
+	ConnID      string    `json:"conn_id"`
+	NodeID      string    `json:"node_id"`
+	Status      string    `json:"status"`
+	Rooms       []string  `json:"rooms,omitempty"`
+	ConnectedAt time.Time `json:"connected_at"`
+	// IP and UserAgent identify the device; they are only shown to the user.
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// ExpiredPresence reports a user whose last connection expired without a clean disconnect,
+// e.g. because its node crashed, together with the rooms that user was present in.
+type ExpiredPresence struct {
+	UserID string
+	Rooms  []string
+}
+
+// AggregateStatus folds the statuses of a user's connections: online if any connection
+// is online, away if all are away, offline if there are none.
+func AggregateStatus(conns []Presence) string {
+	if len(conns) == 0 {
+		return StatusOffline
+	}
+	for _, c := range conns {
+		if c.Status != StatusAway {
+			return StatusOnline
+		}
+	}
+	return StatusAway
+}
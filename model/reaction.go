@@ -0,0 +1,23 @@
+// model/reaction.go
+package model
+
+import "time"
+
+// MessageReaction is one user's emoji reaction to a message. A user reacts with a given
+// emoji at most once per message.
+type MessageReaction struct {
+	ID        int64     `json:"id"`
+	MessageID int64     `json:"message_id"`
+	UserID    string    `json:"user_id"`
+	Emoji     string    `json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName maps MessageReaction to the message_reactions table.
+func (MessageReaction) TableName() string { return "message_reactions" }
+
+// ReactionCount is the number of users who reacted to a message with an emoji.
+type ReactionCount struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,22 @@
+// model/receipt.go
+package model
+
+import "time"
+
+// ReadReceipt is a user's read watermark in a room: every message up to LastReadID has been read.
+type ReadReceipt struct {
+	RoomID     string    `json:"room_id" gorm:"primaryKey"`
+	UserID     string    `json:"user_id" gorm:"primaryKey"`
+	LastReadID int64     `json:"last_read_id"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName maps ReadReceipt to the room_read_receipts table.
+func (ReadReceipt) TableName() string { return "room_read_receipts" }
+
+// UnreadCount is the number of messages a user has not read in a room.
+type UnreadCount struct {
+	RoomID     string `json:"room_id"`
+	Unread     int    `json:"unread"`
+	LastReadID int64  `json:"last_read_id"`
+}
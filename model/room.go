@@ -3,15 +3,17 @@ package model
 
 import "sync"
 
-// Room holds connected clients in a chat room.
+// Room holds the local members of a chat room. Membership is tracked per user, and every
+// connection of a member that joined the room receives its traffic.
 type Room struct {
 	Name    string
-	Clients map[string]*ClientConn // Map of client ID to connection info.
-	Mutex   sync.RWMutex           // Protects Clients map.
+	Members map[string]map[string]*Client // User ID -> client ID -> connection.
+	Mutex   sync.RWMutex                  // Protects Members.
 }
 
-// ClientConn holds minimal connection info for a client in a room.
-type ClientConn struct {
-	ID   string
-	Conn *Client // Pointer to the Client.
+// Thread holds the local clients subscribed to the replies of a room message.
+type Thread struct {
+	ParentID int64
+	RoomID   string
+	Clients  map[string]*Client // Map of client ID to client.
 }
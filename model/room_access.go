@@ -0,0 +1,65 @@
+// model/room_access.go
+package model
+
+import "time"
+
+// Room visibilities.
+const (
+	VisibilityPublic     = "public"      // Anyone may join; joining makes the user a member.
+	VisibilityInviteOnly = "invite_only" // Members only; any member may invite.
+	VisibilityPrivate    = "private"     // Members only; only owners and admins may invite, and the room is hidden from others.
+)
+
+// Member roles, from most to least privileged.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Invitation statuses.
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+)
+
+// RoomInfo is a persisted room and its access settings.
+type RoomInfo struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	Visibility string    `json:"visibility"`
+	OwnerID    string    `json:"owner_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName maps RoomInfo to the rooms table.
+func (RoomInfo) TableName() string { return "rooms" }
+
+// RoomMembership grants a user a role in a room.
+type RoomMembership struct {
+	RoomID    string    `json:"room_id" gorm:"primaryKey"`
+	UserID    string    `json:"user_id" gorm:"primaryKey"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName maps RoomMembership to the room_members table.
+func (RoomMembership) TableName() string { return "room_members" }
+
+// RoomInvitation invites a user into a room. A user has at most one invitation per room.
+type RoomInvitation struct {
+	ID         int64      `json:"id"`
+	RoomID     string     `json:"room_id"`
+	InviterID  string     `json:"inviter_id"`
+	InviteeID  string     `json:"invitee_id"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+}
+
+// TableName maps RoomInvitation to the room_invitations table.
+func (RoomInvitation) TableName() string { return "room_invitations" }
+
+// CanManage reports whether the role may manage members (invite into private rooms, moderate).
+func CanManage(role string) bool {
+	return role == RoleOwner || role == RoleAdmin
+}
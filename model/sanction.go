@@ -0,0 +1,39 @@
+// model/sanction.go
+package model
+
+import "time"
+
+// Sanction kinds.
+const (
+	SanctionBan  = "ban"  // The user may not join the room.
+	SanctionMute = "mute" // The user may not post in the room.
+)
+
+// Moderation actions carried by moderation events.
+const (
+	ModerationKick   = "kick"
+	ModerationBan    = "ban"
+	ModerationMute   = "mute"
+	ModerationUnban  = "unban"
+	ModerationUnmute = "unmute"
+)
+
+// RoomSanction is a ban or mute of a user in a room. A nil ExpiresAt never expires.
+type RoomSanction struct {
+	ID          int64      `json:"id"`
+	RoomID      string     `json:"room_id"`
+	UserID      string     `json:"user_id"`
+	Kind        string     `json:"kind"`
+	ModeratorID string     `json:"moderator_id"`
+	Reason      string     `json:"reason,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// TableName maps RoomSanction to the room_sanctions table.
+func (RoomSanction) TableName() string { return "room_sanctions" }
+
+// Active reports whether the sanction is in force at now.
+func (s *RoomSanction) Active(now time.Time) bool {
+	return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
@@ -0,0 +1,13 @@
+// model/session.go
+package model
+
+// Session is the resumable state of a connection: the rooms it had joined, with the last message
+// the client acknowledged in each, and the threads it followed. It is saved as it changes, and
+// when the connection closes, so that a reconnect within the resume window can restore it.
+type Session struct {
+	Token   string           `json:"token"`
+	UserID  string           `json:"user_id"`
+	ConnID  string           `json:"conn_id"` // The connection the session belongs to.
+	Rooms   map[string]int64 `json:"rooms"`   // Room ID -> last acknowledged message ID.
+	Threads []int64          `json:"threads,omitempty"`
+}
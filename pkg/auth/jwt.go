@@ -0,0 +1,228 @@
+// pkg/auth/jwt.go
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTokenMissing     = errors.New("token is missing")
+	ErrTokenMalformed   = errors.New("token is malformed")
+	ErrTokenSignature   = errors.New("token signature is invalid")
+	ErrTokenAlgorithm   = errors.New("token signing algorithm is not accepted")
+	ErrTokenExpired     = errors.New("token is expired")
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	ErrTokenClaims      = errors.New("token claims are invalid")
+)
+
+// Claims holds the registered and profile claims the chat service relies on.
+type Claims struct {
+	Subject   string
+	Name      string
+	Email     string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time // Zero if the token carries no exp claim.
+	NotBefore time.Time
+	IssuedAt  time.Time
+}
+
+// rawClaims mirrors the JSON payload of a token.
+type rawClaims struct {
+	Subject   string          `json:"sub"`
+	Name      string          `json:"name"`
+	Email     string          `json:"email"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt float64         `json:"exp"`
+	NotBefore float64         `json:"nbf"`
+	IssuedAt  float64         `json:"iat"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verifier validates HS256 tokens with a shared secret and RS256 tokens with keys from a JWKS file.
+type Verifier struct {
+	secret   []byte
+	keys     map[string]*rsa.PublicKey
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+// NewVerifier creates a Verifier. Either secret or jwksFile (or both) must be set;
+// issuer and audience are only checked when non-empty.
+func NewVerifier(secret, jwksFile, issuer, audience string) (*Verifier, error) {
+	v := &Verifier{
+		issuer:   issuer,
+		audience: audience,
+		now:      time.Now,
+	}
+	if secret != "" {
+		v.secret = []byte(secret)
+	}
+	if jwksFile != "" {
+		keys, err := LoadJWKSFile(jwksFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+	}
+	if v.secret == nil && len(v.keys) == 0 {
+		return nil, errors.New("auth: either a secret or a JWKS file is required")
+	}
+	return v, nil
+}
+
+// Verify checks the token signature and time-based claims and returns its claims.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	if token == "" {
+		return nil, ErrTokenMissing
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	var hdr header
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err := v.verifySignature(hdr, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var raw rawClaims
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	claims, err := raw.toClaims()
+	if err != nil {
+		return nil, err
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verifySignature checks sig over signingInput with the key matching the header's algorithm.
+func (v *Verifier) verifySignature(hdr header, signingInput string, sig []byte) error {
+	switch hdr.Alg {
+	case "HS256":
+		if v.secret == nil {
+			return ErrTokenAlgorithm
+		}
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrTokenSignature
+		}
+		return nil
+	case "RS256":
+		key := v.rsaKey(hdr.Kid)
+		if key == nil {
+			return ErrTokenSignature
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+			return ErrTokenSignature
+		}
+		return nil
+	default:
+		return ErrTokenAlgorithm
+	}
+}
+
+// rsaKey returns the key for kid, or the only configured key when the token has no kid.
+func (v *Verifier) rsaKey(kid string) *rsa.PublicKey {
+	if key, ok := v.keys[kid]; ok {
+		return key
+	}
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key
+		}
+	}
+	return nil
+}
+
+// validate checks the subject, time window, issuer and audience.
+func (v *Verifier) validate(c *Claims) error {
+	now := v.now()
+	if c.Subject == "" {
+		return fmt.Errorf("%w: sub is required", ErrTokenClaims)
+	}
+	if !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt) {
+		return ErrTokenExpired
+	}
+	if !c.NotBefore.IsZero() && now.Before(c.NotBefore) {
+		return ErrTokenNotYetValid
+	}
+	if v.issuer != "" && c.Issuer != v.issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrTokenClaims)
+	}
+	if v.audience != "" {
+		for _, aud := range c.Audience {
+			if aud == v.audience {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: unexpected audience", ErrTokenClaims)
+	}
+	return nil
+}
+
+// toClaims converts numeric dates and the string-or-array audience.
+func (r rawClaims) toClaims() (*Claims, error) {
+	c := &Claims{
+		Subject:   r.Subject,
+		Name:      r.Name,
+		Email:     r.Email,
+		Issuer:    r.Issuer,
+		ExpiresAt: numericDate(r.ExpiresAt),
+		NotBefore: numericDate(r.NotBefore),
+		IssuedAt:  numericDate(r.IssuedAt),
+	}
+	if len(r.Audience) > 0 {
+		var single string
+		if err := json.Unmarshal(r.Audience, &single); err == nil {
+			c.Audience = []string{single}
+		} else if err := json.Unmarshal(r.Audience, &c.Audience); err != nil {
+			return nil, fmt.Errorf("%w: aud", ErrTokenClaims)
+		}
+	}
+	return c, nil
+}
+
+func numericDate(v float64) time.Time {
+	if v == 0 {
+		return time.Time{}
+	}
+	sec := int64(v)
+	return time.Unix(sec, int64((v-float64(sec))*1e9))
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
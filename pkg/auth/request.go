@@ -0,0 +1,49 @@
+// pkg/auth/request.go
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// TokenProtocol is the WebSocket subprotocol that marks the next offered protocol as a token,
+// e.g. new WebSocket(url, ["access_token", token]).
+const TokenProtocol = "access_token"
+
+type contextKey struct{}
+
+// TokenFromRequest extracts a bearer token from the Authorization header,
+// the Sec-WebSocket-Protocol header or the token query parameter, in that order.
+func TokenFromRequest(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+			return strings.TrimSpace(h[7:])
+		}
+	}
+
+	var protocols []string
+	for _, h := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(h, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+	for i, p := range protocols {
+		if p == TokenProtocol && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+
+	return r.URL.Query().Get("token")
+}
+
+// WithClaims returns a copy of ctx carrying the authenticated claims.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by WithClaims, or nil.
+func ClaimsFromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(contextKey{}).(*Claims)
+	return claims
+}
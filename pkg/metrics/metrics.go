@@ -20,11 +20,77 @@ var (
 			Help: "Total number of errors encountered while reading from the WebSocket.",
 		},
 	)
+	SendQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "websocket_send_queue_depth",
+			Help: "Number of outbound frames currently queued across all client write pumps.",
+		},
+	)
+	SendQueueDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_send_queue_dropped_total",
+			Help: "Total number of outbound frames dropped because a client's send queue was full.",
+		},
+		[]string{"policy"},
+	)
+	SlowConsumerEvictions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "websocket_slow_consumer_evictions_total",
+			Help: "Total number of clients disconnected because their send queue was full.",
+		},
+	)
+	DegradedConnections = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "websocket_degraded_connections",
+			Help: "Number of connections that missed at least one keepalive pong.",
+		},
+	)
+	PongTimeouts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "websocket_pong_timeouts_total",
+			Help: "Total number of connections closed because no pong arrived within the pong wait.",
+		},
+	)
+	AuthFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_auth_failures_total",
+			Help: "Total number of rejected requests by authentication failure reason.",
+		},
+		[]string{"reason"},
+	)
+	ThrottledFrames = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_throttled_frames_total",
+			Help: "Total number of inbound frames rejected by rate limiting, by the limit that was hit (user, ip, room or typing).",
+		},
+		[]string{"scope"},
+	)
+	RateLimitDisconnects = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "websocket_rate_limit_disconnects_total",
+			Help: "Total number of connections closed for repeatedly exceeding rate limits.",
+		},
+	)
+	MentionsDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "websocket_mentions_dropped_total",
+			Help: "Total number of messages whose mentions were not fanned out because the mention queue was full.",
+		},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(MessagesRead)
 	prometheus.MustRegister(ReadErrors)
+	prometheus.MustRegister(SendQueueDepth)
+	prometheus.MustRegister(SendQueueDropped)
+	prometheus.MustRegister(SlowConsumerEvictions)
+	prometheus.MustRegister(DegradedConnections)
+	prometheus.MustRegister(PongTimeouts)
+	prometheus.MustRegister(AuthFailures)
+	prometheus.MustRegister(ThrottledFrames)
+	prometheus.MustRegister(RateLimitDisconnects)
+	prometheus.MustRegister(MentionsDropped)
 }
 
 // StartMetricsServer starts an HTTP server for Prometheus metrics.
@@ -0,0 +1,118 @@
+// pkg/netutil/client_ip.go
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies is a set of networks whose forwarding headers are believed.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses IP addresses and CIDR ranges such as "10.0.0.0/8".
+func ParseTrustedProxies(entries []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// Contains reports whether ip belongs to a trusted proxy.
+func (t TrustedProxies) Contains(ip net.IP) bool {
+	for _, network := range t {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client that made r. The forwarding chain in the Forwarded
+// header, or failing that X-Forwarded-For, is believed only as far as it was appended by trusted
+// proxies: starting from the peer, hops are walked back until one is not a trusted proxy.
+func (t TrustedProxies) ClientIP(r *http.Request) string {
+	peer := hostIP(r.RemoteAddr)
+	ip := net.ParseIP(peer)
+	if ip == nil || !t.Contains(ip) {
+		return peer
+	}
+
+	hops := forwardedFor(r.Header)
+	if len(hops) == 0 {
+		hops = xForwardedFor(r.Header)
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(hops[i])
+		if hop == nil {
+			// An unparsable hop, such as an obfuscated identifier, ends what can be believed.
+			break
+		}
+		client = hop.String()
+		if !t.Contains(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedFor returns the for= addresses of the RFC 7239 Forwarded headers, nearest hop last.
+func forwardedFor(header http.Header) []string {
+	var hops []string
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(key, "for") {
+					continue
+				}
+				hops = append(hops, hostIP(strings.Trim(val, `"`)))
+			}
+		}
+	}
+	return hops
+}
+
+// xForwardedFor returns the addresses of the X-Forwarded-For headers, nearest hop last.
+func xForwardedFor(header http.Header) []string {
+	var hops []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hostIP(hop))
+			}
+		}
+	}
+	return hops
+}
+
+// hostIP strips the port and IPv6 brackets from an address.
+func hostIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
@@ -0,0 +1,181 @@
+// pkg/netutil/client_ip_test.go
+package netutil
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		trusted []string
+		other   []string
+		wantErr bool
+	}{
+		{
+			name:    "bare IPs next to CIDR ranges",
+			entries: []string{"10.0.0.0/8", " 192.168.1.5 ", "", "2001:db8::/32", "::1"},
+			trusted: []string{"10.1.2.3", "192.168.1.5", "2001:db8::7", "::1"},
+			other:   []string{"11.0.0.1", "192.168.1.6", "2001:db9::7", "::2"},
+		},
+		{
+			name:    "bare IPv4 matches its IPv4-mapped form",
+			entries: []string{"192.168.1.5"},
+			trusted: []string{"::ffff:192.168.1.5"},
+			other:   []string{"::ffff:192.168.1.6"},
+		},
+		{name: "invalid address", entries: []string{"10.0.0.300"}, wantErr: true},
+		{name: "invalid prefix length", entries: []string{"10.0.0.0/33"}, wantErr: true},
+		{name: "host name", entries: []string{"proxy.internal"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxies, err := ParseTrustedProxies(tt.entries)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTrustedProxies(%q) succeeded, want an error", tt.entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTrustedProxies(%q): %v", tt.entries, err)
+			}
+			for _, ip := range tt.trusted {
+				if !proxies.Contains(net.ParseIP(ip)) {
+					t.Errorf("%s is not trusted", ip)
+				}
+			}
+			for _, ip := range tt.other {
+				if proxies.Contains(net.ParseIP(ip)) {
+					t.Errorf("%s is trusted", ip)
+				}
+			}
+		})
+	}
+}
+
+func TestTrustedProxiesClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.5", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		xff        string
+		want       string
+	}{
+		{
+			name:       "direct connection",
+			remoteAddr: "203.0.113.7:5000",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "untrusted peer's headers are ignored",
+			remoteAddr: "203.0.113.7:5000",
+			forwarded:  "for=198.51.100.1",
+			xff:        "198.51.100.2",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "trusted peer without headers",
+			remoteAddr: "10.0.0.1:5000",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "X-Forwarded-For through one proxy",
+			remoteAddr: "10.0.0.1:5000",
+			xff:        "198.51.100.2",
+			want:       "198.51.100.2",
+		},
+		{
+			name:       "chain of trusted hops",
+			remoteAddr: "10.0.0.1:5000",
+			xff:        "198.51.100.2, 192.168.1.5, 10.0.0.2",
+			want:       "198.51.100.2",
+		},
+		{
+			name:       "spoofed entries before the first untrusted hop are ignored",
+			remoteAddr: "10.0.0.1:5000",
+			xff:        "1.2.3.4, 198.51.100.2, 10.0.0.2",
+			want:       "198.51.100.2",
+		},
+		{
+			name:       "bare IP proxy next to a CIDR range",
+			remoteAddr: "192.168.1.5:5000",
+			xff:        "198.51.100.2, 10.0.0.2",
+			want:       "198.51.100.2",
+		},
+		{
+			name:       "untrusted address next to a bare IP proxy",
+			remoteAddr: "10.0.0.1:5000",
+			xff:        "198.51.100.2, 192.168.1.6",
+			want:       "192.168.1.6",
+		},
+		{
+			name:       "Forwarded is preferred over X-Forwarded-For",
+			remoteAddr: "10.0.0.1:5000",
+			forwarded:  "for=198.51.100.1;proto=https, for=10.0.0.2",
+			xff:        "198.51.100.2",
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "obfuscated for= value ends the chain",
+			remoteAddr: "10.0.0.1:5000",
+			forwarded:  "for=_hidden, for=10.0.0.2",
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "unknown for= value ends the chain",
+			remoteAddr: "10.0.0.1:5000",
+			forwarded:  "for=unknown",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "unparsable X-Forwarded-For entry ends the chain",
+			remoteAddr: "10.0.0.1:5000",
+			xff:        "198.51.100.2, not-an-ip",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "quoted IPv6 with brackets and port",
+			remoteAddr: "10.0.0.1:5000",
+			forwarded:  `for="[2001:db8::1]:4711"`,
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "IPv6 peer and X-Forwarded-For",
+			remoteAddr: "[fd00::1]:5000",
+			xff:        "2001:db8::2, [fd00::2]:443",
+			want:       "2001:db8::2",
+		},
+		{
+			name:       "untrusted IPv6 peer",
+			remoteAddr: "[2001:db8::3]:5000",
+			xff:        "2001:db8::2",
+			want:       "2001:db8::3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("Forwarded", tt.forwarded)
+			}
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if got := proxies.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
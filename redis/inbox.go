@@ -0,0 +1,133 @@
+// redis/inbox.go
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"chat-websocket/model"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// InboxStore keeps a capped, expiring list of the events queued for each offline user.
+type InboxStore interface {
+	// Push appends an event to a user's inbox and returns its ID. Entries beyond the cap are
+	// removed from the front of the inbox and returned so the caller can keep them elsewhere.
+	Push(ctx context.Context, userID string, event []byte) (int64, []model.InboxEntry, error)
+	// List returns a user's queued entries, oldest first.
+	List(ctx context.Context, userID string) ([]model.InboxEntry, error)
+	// Trim removes a user's entries up to and including upToID.
+	Trim(ctx context.Context, userID string, upToID int64) error
+}
+
+// pushInboxScript assigns the next ID from KEYS[2], appends the entry to the list in KEYS[1]
+// and pops the entries beyond the cap in ARGV[2], returning the ID and the popped entries.
+// ARGV[1] is the entry's JSON without its id; ARGV[3] is the list's TTL in milliseconds.
+// The ID counter does not expire, so IDs keep increasing after the list does.
+var pushInboxScript = goredis.NewScript(`
+local id = redis.call('INCR', KEYS[2])
+redis.call('RPUSH', KEYS[1], '{"id":' .. id .. ',' .. string.sub(ARGV[1], 2))
+local overflow = {}
+local excess = redis.call('LLEN', KEYS[1]) - tonumber(ARGV[2])
+for i = 1, excess do
+  table.insert(overflow, redis.call('LPOP', KEYS[1]))
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {id, overflow}
+`)
+
+// trimInboxScript pops entries from the front of the list in KEYS[1] while their id is at most ARGV[1].
+var trimInboxScript = goredis.NewScript(`
+while true do
+  local head = redis.call('LINDEX', KEYS[1], 0)
+  if not head then
+    break
+  end
+  local id = tonumber(string.match(head, '^{"id":(%d+)'))
+  if id ~= nil and id > tonumber(ARGV[1]) then
+    break
+  end
+  redis.call('LPOP', KEYS[1])
+end
+return 1
+`)
+
+// inboxStore is the Redis implementation of InboxStore.
+type inboxStore struct {
+	client *goredis.Client
+	cap    int
+	ttl    time.Duration
+}
+
+// NewInboxStore creates a Redis backed InboxStore holding at most cap entries per user for ttl
+// after the last push.
+func NewInboxStore(rc *RedisClient, cap int, ttl time.Duration) InboxStore {
+	return &inboxStore{client: rc.GetRawClient(), cap: cap, ttl: ttl}
+}
+
+func inboxKey(userID string) string {
+	return fmt.Sprintf("inbox:user:%s", userID)
+}
+
+func inboxSeqKey(userID string) string {
+	return fmt.Sprintf("inbox:user:%s:seq", userID)
+}
+
+func (s *inboxStore) Push(ctx context.Context, userID string, event []byte) (int64, []model.InboxEntry, error) {
+	// The script splices the ID in as the first field, so the encoded entry must not carry one.
+	entry, err := json.Marshal(struct {
+		UserID    string          `json:"user_id"`
+		Event     json.RawMessage `json:"event"`
+		CreatedAt time.Time       `json:"created_at"`
+	}{userID, event, time.Now().UTC()})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	res, err := pushInboxScript.Run(ctx, s.client, []string{inboxKey(userID), inboxSeqKey(userID)},
+		entry, s.cap, s.ttl.Milliseconds()).Slice()
+	if err != nil {
+		return 0, nil, err
+	}
+	id, _ := res[0].(int64)
+	popped, _ := res[1].([]interface{})
+	return id, decodeInboxEntries(userID, popped), nil
+}
+
+func (s *inboxStore) List(ctx context.Context, userID string) ([]model.InboxEntry, error) {
+	raw, err := s.client.LRange(ctx, inboxKey(userID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(raw))
+	for i, r := range raw {
+		values[i] = r
+	}
+	return decodeInboxEntries(userID, values), nil
+}
+
+func (s *inboxStore) Trim(ctx context.Context, userID string, upToID int64) error {
+	return trimInboxScript.Run(ctx, s.client, []string{inboxKey(userID)}, upToID).Err()
+}
+
+// decodeInboxEntries decodes list items, skipping any that are not valid entries.
+func decodeInboxEntries(userID string, values []interface{}) []model.InboxEntry {
+	entries := make([]model.InboxEntry, 0, len(values))
+	for _, v := range values {
+		raw, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var entry model.InboxEntry
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			log.Printf("Invalid inbox entry for user %s: %v", userID, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,72 @@
+// redis/memory_inbox.go
+package redis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"chat-websocket/model"
+)
+
+// memoryInboxStore is an in-process InboxStore for single-node development and tests.
+// Entries are kept until trimmed; readers skip those past their TTL.
+type memoryInboxStore struct {
+	mu      sync.Mutex
+	cap     int
+	entries map[string][]model.InboxEntry
+	seq     map[string]int64
+}
+
+// NewMemoryInboxStore creates an in-process InboxStore holding at most cap entries per user.
+func NewMemoryInboxStore(cap int) InboxStore {
+	return &memoryInboxStore{
+		cap:     cap,
+		entries: make(map[string][]model.InboxEntry),
+		seq:     make(map[string]int64),
+	}
+}
+
+func (s *memoryInboxStore) Push(ctx context.Context, userID string, event []byte) (int64, []model.InboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq[userID]++
+	id := s.seq[userID]
+	entries := append(s.entries[userID], model.InboxEntry{
+		ID:        id,
+		UserID:    userID,
+		Event:     append([]byte(nil), event...),
+		CreatedAt: time.Now().UTC(),
+	})
+	var overflow []model.InboxEntry
+	if excess := len(entries) - s.cap; excess > 0 {
+		overflow = append(overflow, entries[:excess]...)
+		entries = entries[excess:]
+	}
+	s.entries[userID] = entries
+	return id, overflow, nil
+}
+
+func (s *memoryInboxStore) List(ctx context.Context, userID string) ([]model.InboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]model.InboxEntry(nil), s.entries[userID]...), nil
+}
+
+func (s *memoryInboxStore) Trim(ctx context.Context, userID string, upToID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.entries[userID]
+	for len(entries) > 0 && entries[0].ID <= upToID {
+		entries = entries[1:]
+	}
+	if len(entries) == 0 {
+		delete(s.entries, userID)
+	} else {
+		s.entries[userID] = entries
+	}
+	return nil
+}
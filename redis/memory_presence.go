@@ -0,0 +1,132 @@
+// redis/memory_presence.go
+package redis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"chat-websocket/model"
+)
+
+// memoryPresenceRepository is an in-process PresenceRepository for single-node development and tests.
+type memoryPresenceRepository struct {
+	mu    sync.Mutex
+	users map[string]map[string]*memoryPresenceEntry // User ID -> connection ID -> entry.
+}
+
+type memoryPresenceEntry struct {
+	presence model.Presence
+	expiry   time.Time
+}
+
+// NewMemoryPresenceRepository creates an in-process PresenceRepository.
+func NewMemoryPresenceRepository() PresenceRepository {
+	return &memoryPresenceRepository{users: make(map[string]map[string]*memoryPresenceEntry)}
+}
+
+func (r *memoryPresenceRepository) Touch(ctx context.Context, p model.Presence, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conns, ok := r.users[p.UserID]
+	if !ok {
+		conns = make(map[string]*memoryPresenceEntry)
+		r.users[p.UserID] = conns
+	}
+	p.Rooms = append([]string(nil), p.Rooms...)
+	conns[p.ConnID] = &memoryPresenceEntry{presence: p, expiry: time.Now().Add(ttl)}
+	return nil
+}
+
+func (r *memoryPresenceRepository) Remove(ctx context.Context, p model.Presence) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conns := r.users[p.UserID]
+	delete(conns, p.ConnID)
+	live := len(r.liveLocked(p.UserID))
+	if len(conns) == 0 {
+		delete(r.users, p.UserID)
+	}
+	return live, nil
+}
+
+func (r *memoryPresenceRepository) RemoveRoom(ctx context.Context, roomID, userID, connID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.users[userID][connID]; ok {
+		rooms := entry.presence.Rooms[:0]
+		for _, room := range entry.presence.Rooms {
+			if room != roomID {
+				rooms = append(rooms, room)
+			}
+		}
+		entry.presence.Rooms = rooms
+	}
+	return nil
+}
+
+func (r *memoryPresenceRepository) UserConnections(ctx context.Context, userID string) ([]model.Presence, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.liveLocked(userID), nil
+}
+
+func (r *memoryPresenceRepository) RoomMembers(ctx context.Context, roomID string) ([]model.Presence, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var result []model.Presence
+	for userID := range r.users {
+		for _, p := range r.liveLocked(userID) {
+			for _, room := range p.Rooms {
+				if room == roomID {
+					result = append(result, p)
+					break
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryPresenceRepository) ReapExpired(ctx context.Context, nodeID string, interval time.Duration) ([]model.ExpiredPresence, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var offline []model.ExpiredPresence
+	for userID, conns := range r.users {
+		var rooms []string
+		for connID, entry := range conns {
+			if entry.expiry.After(now) {
+				continue
+			}
+			for _, room := range entry.presence.Rooms {
+				rooms = appendUnique(rooms, room)
+			}
+			delete(conns, connID)
+		}
+		if len(conns) == 0 {
+			delete(r.users, userID)
+			offline = append(offline, model.ExpiredPresence{UserID: userID, Rooms: rooms})
+		}
+	}
+	return offline, nil
+}
+
+// liveLocked returns copies of the user's unexpired connections; r.mu must be held.
+func (r *memoryPresenceRepository) liveLocked(userID string) []model.Presence {
+	now := time.Now()
+	var result []model.Presence
+	for _, entry := range r.users[userID] {
+		if entry.expiry.After(now) {
+			p := entry.presence
+			p.Rooms = append([]string(nil), p.Rooms...)
+			result = append(result, p)
+		}
+	}
+	return result
+}
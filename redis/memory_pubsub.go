@@ -0,0 +1,119 @@
+// redis/memory_pubsub.go
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"chat-websocket/model"
+)
+
+// memoryPubSubRepository is an in-process PubSubRepository for single-node development and tests.
+// Events round-trip through JSON like they would over Redis, and are delivered by one dispatcher
+// goroutine so that publishing while holding locks never re-enters the caller.
+type memoryPubSubRepository struct {
+	mu       sync.Mutex
+	handlers map[string]func(*model.Event) // Keyed by topic.
+	queue    []memoryDelivery
+	notify   chan struct{}
+	done     chan struct{}
+	closed   bool
+}
+
+type memoryDelivery struct {
+	topic   string
+	payload []byte
+}
+
+// NewMemoryPubSubRepository creates an in-process PubSubRepository.
+func NewMemoryPubSubRepository() PubSubRepository {
+	r := &memoryPubSubRepository{
+		handlers: make(map[string]func(*model.Event)),
+		notify:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go r.dispatch()
+	return r
+}
+
+// Publish queues an event for the topic's local subscriber, if any.
+func (r *memoryPubSubRepository) Publish(ctx context.Context, topic string, event *model.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal event: %v\n", err)
+		return err
+	}
+
+	r.mu.Lock()
+	if !r.closed {
+		r.queue = append(r.queue, memoryDelivery{topic: topic, payload: payload})
+	}
+	r.mu.Unlock()
+
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Subscribe registers handler for the topic's events.
+func (r *memoryPubSubRepository) Subscribe(ctx context.Context, topic string, handler func(*model.Event)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[topic] = handler
+}
+
+// Unsubscribe removes the topic's handler.
+func (r *memoryPubSubRepository) Unsubscribe(ctx context.Context, topic string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.handlers, topic)
+}
+
+// Close stops the dispatcher and drops undelivered events.
+func (r *memoryPubSubRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		r.closed = true
+		r.queue = nil
+		close(r.done)
+	}
+	return nil
+}
+
+// dispatch delivers queued events in publish order until Close is called.
+func (r *memoryPubSubRepository) dispatch() {
+	for {
+		select {
+		case <-r.notify:
+		case <-r.done:
+			return
+		}
+
+		for {
+			r.mu.Lock()
+			if len(r.queue) == 0 {
+				r.mu.Unlock()
+				break
+			}
+			delivery := r.queue[0]
+			r.queue = r.queue[1:]
+			handler := r.handlers[delivery.topic]
+			r.mu.Unlock()
+
+			if handler == nil {
+				continue
+			}
+			var event model.Event
+			if err := json.Unmarshal(delivery.payload, &event); err != nil {
+				log.Printf("Invalid event for topic %s: %v\n", delivery.topic, err)
+				continue
+			}
+			handler(&event)
+		}
+	}
+}
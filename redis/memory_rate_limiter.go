@@ -0,0 +1,74 @@
+// redis/memory_rate_limiter.go
+package redis
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	memorySweepThreshold = 10000
+	// memoryBucketIdle bounds how long an unused bucket is kept; it must exceed the slowest refill.
+	memoryBucketIdle = 10 * time.Minute
+)
+
+// memoryRateLimiter is an in-process RateLimiter for single-node development and tests.
+type memoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+// NewMemoryRateLimiter creates an in-process RateLimiter.
+func NewMemoryRateLimiter() RateLimiter {
+	return &memoryRateLimiter{buckets: make(map[string]*memoryBucket)}
+}
+
+func (r *memoryRateLimiter) Allow(ctx context.Context, buckets []RateBucket) (int, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	tokens := make([]float64, len(buckets))
+	for i, b := range buckets {
+		if b.Limit.Burst <= 0 || b.Limit.Rate <= 0 {
+			continue
+		}
+		tk := float64(b.Limit.Burst)
+		if state, ok := r.buckets[b.Key]; ok {
+			tk = math.Min(tk, state.tokens+now.Sub(state.ts).Seconds()*b.Limit.Rate)
+		}
+		if tk < 1 {
+			wait := time.Duration((1 - tk) / b.Limit.Rate * float64(time.Second))
+			return i, wait, nil
+		}
+		tokens[i] = tk
+	}
+
+	for i, b := range buckets {
+		if b.Limit.Burst <= 0 || b.Limit.Rate <= 0 {
+			continue
+		}
+		r.buckets[b.Key] = &memoryBucket{tokens: tokens[i] - 1, ts: now}
+	}
+	r.sweep(now)
+	return -1, 0, nil
+}
+
+// sweep drops buckets idle for memoryBucketIdle once the map grows large. The caller must hold r.mu.
+func (r *memoryRateLimiter) sweep(now time.Time) {
+	if len(r.buckets) < memorySweepThreshold {
+		return
+	}
+	for key, state := range r.buckets {
+		if now.Sub(state.ts) > memoryBucketIdle {
+			delete(r.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// redis/memory_receipt_cache.go
+package redis
+
+import (
+	"context"
+	"sync"
+)
+
+// memoryReadReceiptCache is an in-process ReadReceiptCache for single-node development and tests.
+// Entries never expire.
+type memoryReadReceiptCache struct {
+	mu       sync.Mutex
+	users    map[string]map[string]int64 // User ID -> room ID -> watermark.
+	complete map[string]bool
+}
+
+// NewMemoryReadReceiptCache creates an in-process ReadReceiptCache.
+func NewMemoryReadReceiptCache() ReadReceiptCache {
+	return &memoryReadReceiptCache{
+		users:    make(map[string]map[string]int64),
+		complete: make(map[string]bool),
+	}
+}
+
+func (c *memoryReadReceiptCache) Advance(ctx context.Context, userID string, watermarks map[string]int64, complete bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rooms, ok := c.users[userID]
+	if !ok {
+		rooms = make(map[string]int64)
+		c.users[userID] = rooms
+	}
+	for roomID, id := range watermarks {
+		if id > rooms[roomID] {
+			rooms[roomID] = id
+		}
+	}
+	if complete {
+		c.complete[userID] = true
+	}
+	return nil
+}
+
+func (c *memoryReadReceiptCache) Watermarks(ctx context.Context, userID string) (map[string]int64, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.complete[userID] {
+		return nil, false, nil
+	}
+	watermarks := make(map[string]int64, len(c.users[userID]))
+	for roomID, id := range c.users[userID] {
+		watermarks[roomID] = id
+	}
+	return watermarks, true, nil
+}
@@ -0,0 +1,74 @@
+// redis/memory_session_store.go
+package redis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"chat-websocket/model"
+)
+
+// memorySessionStore is an in-process SessionStore for single-node development and tests.
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	session model.Session
+	expires time.Time
+}
+
+// NewMemorySessionStore creates an in-process SessionStore.
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{sessions: make(map[string]memorySession)}
+}
+
+func (s *memorySessionStore) Save(ctx context.Context, session *model.Session, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saveLocked(session, ttl)
+	return nil
+}
+
+func (s *memorySessionStore) Update(ctx context.Context, session *model.Session, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.sessions[session.Token]
+	if !ok || time.Now().After(stored.expires) {
+		return false, nil
+	}
+	s.saveLocked(session, ttl)
+	return true, nil
+}
+
+// saveLocked stores a copy of a session, dropping expired ones. The caller must hold s.mu.
+func (s *memorySessionStore) saveLocked(session *model.Session, ttl time.Duration) {
+	now := time.Now()
+	for token, stored := range s.sessions {
+		if now.After(stored.expires) {
+			delete(s.sessions, token)
+		}
+	}
+	saved := *session
+	saved.Rooms = make(map[string]int64, len(session.Rooms))
+	for roomID, lastID := range session.Rooms {
+		saved.Rooms[roomID] = lastID
+	}
+	saved.Threads = append([]int64(nil), session.Threads...)
+	s.sessions[session.Token] = memorySession{session: saved, expires: now.Add(ttl)}
+}
+
+func (s *memorySessionStore) Take(ctx context.Context, token string) (*model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.sessions[token]
+	delete(s.sessions, token)
+	if !ok || time.Now().After(stored.expires) {
+		return nil, nil
+	}
+	return &stored.session, nil
+}
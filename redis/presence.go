@@ -0,0 +1,311 @@
+// redis/presence.go
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"chat-websocket/model"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+const (
+	presenceExpiryKey = "presence:expiry" // ZSET of every connection as "user|conn", scored by expiry.
+	presenceReaperKey = "presence:reaper" // Lock held by the node currently reaping.
+	// presenceKeyTTLFactor keeps keys alive well past their entries' TTL so that the
+	// reaper still finds expired entries (and emits offline events) before Redis drops them.
+	presenceKeyTTLFactor = 10
+	// presenceReapBatch bounds the connections claimed by one reap script call.
+	presenceReapBatch = 500
+)
+
+// reapPresenceScript atomically claims up to ARGV[2] connections that expired by ARGV[1] from the
+// expiry ZSET (KEYS[1]) and returns them as "user|conn" members. A claimed connection is gone
+// from the ZSET, so concurrent reapers never claim it twice. The script touches only the key it
+// is given, so it runs on Redis Cluster; the per-user cleanup is done by the caller.
+var reapPresenceScript = goredis.NewScript(`
+local members = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+if #members > 0 then
+	redis.call('ZREM', KEYS[1], unpack(members))
+end
+return members
+`)
+
+// PresenceRepository records which users are connected to which nodes and rooms.
+// Entries carry an expiry that the owning node refreshes by heartbeat; entries of a crashed
+// node stop being refreshed and are reaped.
+type PresenceRepository interface {
+	// Touch records or refreshes a connection and its rooms for ttl.
+	Touch(ctx context.Context, p model.Presence, ttl time.Duration) error
+	// Remove deletes a connection and its room entries, returning the user's remaining live connections.
+	Remove(ctx context.Context, p model.Presence) (int, error)
+	// RemoveRoom deletes one connection's entry from a room.
+	RemoveRoom(ctx context.Context, roomID, userID, connID string) error
+	// UserConnections returns the live connections of a user.
+	UserConnections(ctx context.Context, userID string) ([]model.Presence, error)
+	// RoomMembers returns the live connections present in a room.
+	RoomMembers(ctx context.Context, roomID string) ([]model.Presence, error)
+	// ReapExpired removes expired entries and reports users left with no live connection.
+	// Nodes take turns reaping; a node that does not get the turn gets an empty result.
+	ReapExpired(ctx context.Context, nodeID string, interval time.Duration) ([]model.ExpiredPresence, error)
+}
+
+// presenceRepository is the Redis implementation of PresenceRepository.
+// Per user, a ZSET scores connection IDs by expiry and a HASH holds their details;
+// per room, a ZSET scores "user|conn" members by expiry. One global ZSET scores every
+// connection by expiry so that reaping reads only what has expired.
+type presenceRepository struct {
+	client *goredis.Client
+}
+
+// NewPresenceRepository creates a Redis backed PresenceRepository.
+func NewPresenceRepository(rc *RedisClient) PresenceRepository {
+	return &presenceRepository{client: rc.GetRawClient()}
+}
+
+func presenceConnsKey(userID string) string {
+	return fmt.Sprintf("presence:user:%s:conns", userID)
+}
+
+func presenceInfoKey(userID string) string {
+	return fmt.Sprintf("presence:user:%s:info", userID)
+}
+
+func presenceRoomKey(roomID string) string {
+	return fmt.Sprintf("presence:room:%s", roomID)
+}
+
+func presenceMember(userID, connID string) string {
+	return userID + "|" + connID
+}
+
+// splitPresenceMember splits a room member into user and connection IDs.
+func splitPresenceMember(member string) (string, string) {
+	i := strings.LastIndex(member, "|")
+	if i < 0 {
+		return member, ""
+	}
+	return member[:i], member[i+1:]
+}
+
+func msScore(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+func (r *presenceRepository) Touch(ctx context.Context, p model.Presence, ttl time.Duration) error {
+	info, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	expiry := float64(time.Now().Add(ttl).UnixMilli())
+	keyTTL := ttl * presenceKeyTTLFactor
+
+	pipe := r.client.TxPipeline()
+	pipe.ZAdd(ctx, presenceConnsKey(p.UserID), &goredis.Z{Score: expiry, Member: p.ConnID})
+	pipe.HSet(ctx, presenceInfoKey(p.UserID), p.ConnID, info)
+	pipe.Expire(ctx, presenceConnsKey(p.UserID), keyTTL)
+	pipe.Expire(ctx, presenceInfoKey(p.UserID), keyTTL)
+	pipe.ZAdd(ctx, presenceExpiryKey, &goredis.Z{Score: expiry, Member: presenceMember(p.UserID, p.ConnID)})
+	for _, room := range p.Rooms {
+		pipe.ZAdd(ctx, presenceRoomKey(room), &goredis.Z{Score: expiry, Member: presenceMember(p.UserID, p.ConnID)})
+		pipe.Expire(ctx, presenceRoomKey(room), keyTTL)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *presenceRepository) Remove(ctx context.Context, p model.Presence) (int, error) {
+	pipe := r.client.TxPipeline()
+	pipe.ZRem(ctx, presenceConnsKey(p.UserID), p.ConnID)
+	pipe.HDel(ctx, presenceInfoKey(p.UserID), p.ConnID)
+	pipe.ZRem(ctx, presenceExpiryKey, presenceMember(p.UserID, p.ConnID))
+	for _, room := range p.Rooms {
+		pipe.ZRem(ctx, presenceRoomKey(room), presenceMember(p.UserID, p.ConnID))
+	}
+	live := pipe.ZCount(ctx, presenceConnsKey(p.UserID), "("+msScore(time.Now()), "+inf")
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(live.Val()), nil
+}
+
+func (r *presenceRepository) RemoveRoom(ctx context.Context, roomID, userID, connID string) error {
+	return r.client.ZRem(ctx, presenceRoomKey(roomID), presenceMember(userID, connID)).Err()
+}
+
+func (r *presenceRepository) UserConnections(ctx context.Context, userID string) ([]model.Presence, error) {
+	connIDs, err := r.client.ZRangeByScore(ctx, presenceConnsKey(userID), &goredis.ZRangeBy{
+		Min: "(" + msScore(time.Now()),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return r.loadInfo(ctx, userID, connIDs)
+}
+
+func (r *presenceRepository) RoomMembers(ctx context.Context, roomID string) ([]model.Presence, error) {
+	members, err := r.client.ZRangeByScore(ctx, presenceRoomKey(roomID), &goredis.ZRangeBy{
+		Min: "(" + msScore(time.Now()),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[string][]string)
+	var order []string
+	for _, m := range members {
+		userID, connID := splitPresenceMember(m)
+		if _, seen := byUser[userID]; !seen {
+			order = append(order, userID)
+		}
+		byUser[userID] = append(byUser[userID], connID)
+	}
+
+	var result []model.Presence
+	for _, userID := range order {
+		conns, err := r.loadInfo(ctx, userID, byUser[userID])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, conns...)
+	}
+	return result, nil
+}
+
+// loadInfo reads the details of the given connections of a user.
+func (r *presenceRepository) loadInfo(ctx context.Context, userID string, connIDs []string) ([]model.Presence, error) {
+	if len(connIDs) == 0 {
+		return nil, nil
+	}
+	values, err := r.client.HMGet(ctx, presenceInfoKey(userID), connIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	conns := make([]model.Presence, 0, len(values))
+	for _, v := range values {
+		raw, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var p model.Presence
+		if err := json.Unmarshal([]byte(raw), &p); err != nil {
+			log.Printf("Invalid presence entry for user %s: %v", userID, err)
+			continue
+		}
+		conns = append(conns, p)
+	}
+	return conns, nil
+}
+
+func (r *presenceRepository) ReapExpired(ctx context.Context, nodeID string, interval time.Duration) ([]model.ExpiredPresence, error) {
+	// The lock is left to expire rather than released so the cluster reaps about once per
+	// interval. It expires halfway through so a missed turn is retaken within the interval;
+	// claiming is atomic, so overlapping reaps do not report a connection twice.
+	lock := NewDistributedLock(r.client, presenceReaperKey, nodeID, interval/2)
+	ok, err := lock.Acquire(ctx)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	now := msScore(time.Now())
+	var offline []model.ExpiredPresence
+	for {
+		claimed, err := reapPresenceScript.Run(ctx, r.client, []string{presenceExpiryKey}, now, presenceReapBatch).StringSlice()
+		if err != nil {
+			return offline, err
+		}
+		expired, err := r.removeClaimed(ctx, claimed, now)
+		offline = append(offline, expired...)
+		if err != nil {
+			return offline, err
+		}
+		if len(claimed) < presenceReapBatch {
+			return offline, nil
+		}
+	}
+}
+
+// removeClaimed deletes the user and room entries of connections claimed by the reap script
+// and returns the users left with no live connection, with the rooms their expired connections
+// were in.
+func (r *presenceRepository) removeClaimed(ctx context.Context, claimed []string, now string) ([]model.ExpiredPresence, error) {
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+
+	type expiredConn struct {
+		userID, connID string
+		info           *goredis.StringCmd
+	}
+	conns := make([]expiredConn, 0, len(claimed))
+	pipe := r.client.Pipeline()
+	for _, member := range claimed {
+		userID, connID := splitPresenceMember(member)
+		conns = append(conns, expiredConn{userID: userID, connID: connID, info: pipe.HGet(ctx, presenceInfoKey(userID), connID)})
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, goredis.Nil) {
+		return nil, err
+	}
+
+	var offline []model.ExpiredPresence
+	index := make(map[string]int)            // User ID -> position in offline.
+	live := make(map[string]*goredis.IntCmd) // User ID -> count of live connections left.
+	pipe = r.client.Pipeline()
+	for _, c := range conns {
+		var p model.Presence
+		if info, err := c.info.Result(); err == nil {
+			if err := json.Unmarshal([]byte(info), &p); err != nil {
+				log.Printf("Invalid presence entry for user %s: %v", c.userID, err)
+			}
+		}
+		pipe.ZRem(ctx, presenceConnsKey(c.userID), c.connID)
+		pipe.HDel(ctx, presenceInfoKey(c.userID), c.connID)
+		for _, room := range p.Rooms {
+			pipe.ZRem(ctx, presenceRoomKey(room), presenceMember(c.userID, c.connID))
+		}
+
+		i, seen := index[c.userID]
+		if !seen {
+			i = len(offline)
+			index[c.userID] = i
+			offline = append(offline, model.ExpiredPresence{UserID: c.userID})
+		}
+		for _, room := range p.Rooms {
+			offline[i].Rooms = appendUnique(offline[i].Rooms, room)
+		}
+	}
+	// Counted after the removals above, which the pipeline sends first.
+	for _, user := range offline {
+		live[user.UserID] = pipe.ZCount(ctx, presenceConnsKey(user.UserID), "("+now, "+inf")
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	result := offline[:0]
+	for _, user := range offline {
+		if live[user.UserID].Val() == 0 {
+			result = append(result, user)
+		}
+	}
+	return result, nil
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, existing := range list {
+		if existing == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
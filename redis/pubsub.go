@@ -7,37 +7,65 @@ import (
 	"fmt"
 	"log"
 	"sync"
-	"time"
+
+	"chat-websocket/model"
 
 	goredis "github.com/go-redis/redis/v8"
 )
 
-// PubSubRepository defines an interface for Redis Pub/Sub operations.
+// PubSubRepository defines an interface for cross-node event fan-out.
+// Topics name the audience of an event and are built with RoomTopic, UserTopic or ThreadTopic.
 type PubSubRepository interface {
-	Publish(ctx context.Context, roomName string, message interface{}) error
-	Subscribe(ctx context.Context, roomName string, handler func([]byte))
-	Unsubscribe(ctx context.Context, roomName string)
+	Publish(ctx context.Context, topic string, event *model.Event) error
+	// Subscribe registers handler for the topic's events on this node and returns immediately.
+	// Handlers are called from a single dispatcher goroutine and must not block.
+	Subscribe(ctx context.Context, topic string, handler func(*model.Event))
+	// Unsubscribe stops delivering the topic's events to this node.
+	Unsubscribe(ctx context.Context, topic string)
+	// Close tears down the node's subscriptions.
+	Close() error
+}
+
+// RoomTopic returns the topic carrying a room's events.
+func RoomTopic(roomName string) string {
+	return fmt.Sprintf("room:%s", roomName)
+}
+
+// UserTopic returns the topic carrying events addressed to all connections of one user.
+func UserTopic(userID string) string {
+	return fmt.Sprintf("user:%s", userID)
+}
+
+// ThreadTopic returns the topic carrying the replies to a message and the changes to them.
+func ThreadTopic(parentID int64) string {
+	return fmt.Sprintf("thread:%d", parentID)
 }
 
 // pubSubRepository is a concrete implementation of PubSubRepository.
+// All topics on a node share one multiplexed Redis Pub/Sub connection whose channel set
+// grows and shrinks with the local rooms and users; go-redis re-subscribes it after reconnects.
+// Each topic maps to the Redis channel of the same name.
 type pubSubRepository struct {
-	client      *goredis.Client
-	subscribeMu sync.Mutex
+	client   *goredis.Client
+	mu       sync.Mutex
+	pubsub   *goredis.PubSub               // Created on first Subscribe.
+	handlers map[string]func(*model.Event) // Keyed by channel.
 }
 
 // NewPubSubRepository creates a new instance of pubSubRepository.
 func NewPubSubRepository(rc *RedisClient) PubSubRepository {
 	return &pubSubRepository{
-		client: rc.GetRawClient(),
+		client:   rc.GetRawClient(),
+		handlers: make(map[string]func(*model.Event)),
 	}
 }
 
-// Publish publishes a message to a Redis channel for the specified room.
-func (r *pubSubRepository) Publish(ctx context.Context, roomName string, message interface{}) error {
-	channel := fmt.Sprintf("room:%s", roomName)
-	msgJSON, err := json.Marshal(message)
+// Publish publishes an event to the topic's Redis channel.
+func (r *pubSubRepository) Publish(ctx context.Context, topic string, event *model.Event) error {
+	channel := topic
+	msgJSON, err := json.Marshal(event)
 	if err != nil {
-		log.Printf("Failed to marshal message: %v\n", err)
+		log.Printf("Failed to marshal event: %v\n", err)
 		return err
 	}
 
@@ -48,32 +76,70 @@ func (r *pubSubRepository) Publish(ctx context.Context, roomName string, message
 	return nil
 }
 
-// Subscribe listens for messages on the given room's channel and invokes handler on each message.
-func (r *pubSubRepository) Subscribe(ctx context.Context, roomName string, handler func([]byte)) {
-	channel := fmt.Sprintf("room:%s", roomName)
-	r.subscribeMu.Lock()
-	defer r.subscribeMu.Unlock()
+// Subscribe adds the topic's channel to the node's shared subscription.
+func (r *pubSubRepository) Subscribe(ctx context.Context, topic string, handler func(*model.Event)) {
+	channel := topic
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	for {
-		pubsub := r.client.Subscribe(ctx, channel)
-		ch := pubsub.Channel()
+	r.handlers[channel] = handler
+	if r.pubsub == nil {
+		r.pubsub = r.client.Subscribe(ctx, channel)
+		go r.dispatch(r.pubsub)
+		return
+	}
+	if err := r.pubsub.Subscribe(ctx, channel); err != nil {
+		log.Printf("Failed to subscribe to channel %s: %v\n", channel, err)
+	}
+}
 
-		for msg := range ch {
-			handler([]byte(msg.Payload))
+// dispatch routes messages from the shared subscription to the handler registered for their channel.
+// It returns when the subscription is closed.
+func (r *pubSubRepository) dispatch(pubsub *goredis.PubSub) {
+	for msg := range pubsub.Channel() {
+		r.mu.Lock()
+		handler := r.handlers[msg.Channel]
+		r.mu.Unlock()
+		if handler == nil {
+			// Late message for a channel that was just unsubscribed.
+			continue
 		}
 
-		// If the subscription ends unexpectedly, wait and try again.
-		time.Sleep(2 * time.Second)
-		log.Printf("Reconnecting to Redis channel %s...", channel)
+		var event model.Event
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			log.Printf("Invalid event on channel %s: %v\n", msg.Channel, err)
+			continue
+		}
+		handler(&event)
 	}
+	log.Println("Redis Pub/Sub dispatcher stopped.")
 }
 
-// Unsubscribe unsubscribes from the specified room channel.
-func (r *pubSubRepository) Unsubscribe(ctx context.Context, roomName string) {
-	channel := fmt.Sprintf("room:%s", roomName)
-	pubsub := r.client.Subscribe(ctx, channel)
-	if err := pubsub.Unsubscribe(ctx, channel); err != nil {
+// Unsubscribe removes the topic's channel from the node's shared subscription.
+func (r *pubSubRepository) Unsubscribe(ctx context.Context, topic string) {
+	channel := topic
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.handlers, channel)
+	if r.pubsub == nil {
+		return
+	}
+	if err := r.pubsub.Unsubscribe(ctx, channel); err != nil {
 		log.Printf("Failed to unsubscribe from channel %s: %v\n", channel, err)
 	}
-	_ = pubsub.Close()
+}
+
+// Close closes the shared subscription, which stops the dispatcher.
+func (r *pubSubRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers = make(map[string]func(*model.Event))
+	if r.pubsub == nil {
+		return nil
+	}
+	err := r.pubsub.Close()
+	r.pubsub = nil
+	return err
 }
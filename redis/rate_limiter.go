@@ -0,0 +1,98 @@
+// redis/rate_limiter.go
+package redis
+
+import (
+	"context"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// RateLimit configures a token bucket: it holds at most Burst tokens and refills at Rate tokens
+// per second. A Burst of zero or less disables the bucket.
+type RateLimit struct {
+	Burst int
+	Rate  float64
+}
+
+// RateBucket is one token bucket to draw from.
+type RateBucket struct {
+	Key   string
+	Limit RateLimit
+}
+
+// RateLimiter draws tokens from token buckets shared by all nodes.
+type RateLimiter interface {
+	// Allow takes one token from every bucket if each has one, and otherwise takes none. It returns
+	// the index of the first bucket that was empty, or -1, and how long until that bucket refills.
+	Allow(ctx context.Context, buckets []RateBucket) (int, time.Duration, error)
+}
+
+// tokenBucketScript checks and drains the buckets in KEYS atomically. ARGV holds a burst and a
+// refill rate (tokens per second) per key. Each bucket is a hash of its token count and the time
+// of the last update; idle buckets expire once they would be full again. The server clock is used
+// so that all nodes agree on refills.
+var tokenBucketScript = goredis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local tokens = {}
+for i = 1, #KEYS do
+  local burst = tonumber(ARGV[2 * i - 1])
+  local rate = tonumber(ARGV[2 * i])
+  local state = redis.call('HMGET', KEYS[i], 'tokens', 'ts')
+  local tk = tonumber(state[1])
+  local ts = tonumber(state[2])
+  if tk == nil then
+    tk = burst
+    ts = now
+  end
+  tk = math.min(burst, tk + (now - ts) * rate / 1000)
+  if tk < 1 then
+    return {i, math.ceil((1 - tk) * 1000 / rate)}
+  end
+  tokens[i] = tk
+end
+for i = 1, #KEYS do
+  local burst = tonumber(ARGV[2 * i - 1])
+  local rate = tonumber(ARGV[2 * i])
+  redis.call('HSET', KEYS[i], 'tokens', tokens[i] - 1, 'ts', now)
+  redis.call('PEXPIRE', KEYS[i], math.ceil(burst * 1000 / rate) + 1000)
+end
+return {0, 0}
+`)
+
+// rateLimiter is the Redis implementation of RateLimiter.
+type rateLimiter struct {
+	client *goredis.Client
+}
+
+// NewRateLimiter creates a Redis backed RateLimiter.
+func NewRateLimiter(rc *RedisClient) RateLimiter {
+	return &rateLimiter{client: rc.GetRawClient()}
+}
+
+func (r *rateLimiter) Allow(ctx context.Context, buckets []RateBucket) (int, time.Duration, error) {
+	var keys []string
+	var args []interface{}
+	var indexes []int // Position in buckets of each key.
+	for i, b := range buckets {
+		if b.Limit.Burst <= 0 || b.Limit.Rate <= 0 {
+			continue
+		}
+		keys = append(keys, b.Key)
+		args = append(args, b.Limit.Burst, b.Limit.Rate)
+		indexes = append(indexes, i)
+	}
+	if len(keys) == 0 {
+		return -1, 0, nil
+	}
+
+	res, err := tokenBucketScript.Run(ctx, r.client, keys, args...).Int64Slice()
+	if err != nil {
+		return -1, 0, err
+	}
+	if res[0] == 0 {
+		return -1, 0, nil
+	}
+	return indexes[res[0]-1], time.Duration(res[1]) * time.Millisecond, nil
+}
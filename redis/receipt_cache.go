@@ -0,0 +1,96 @@
+// redis/receipt_cache.go
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+const (
+	// receiptCompleteField marks a user's cached watermarks as a full copy of the database.
+	receiptCompleteField = "complete"
+	// receiptRoomPrefix prefixes the room fields so that no room ID collides with the marker.
+	receiptRoomPrefix = "room:"
+)
+
+// ReadReceiptCache caches users' read watermarks per room in front of the database.
+type ReadReceiptCache interface {
+	// Advance raises a user's cached watermarks to the given message IDs; lower IDs are ignored,
+	// so concurrent writers and loaders cannot move a watermark back. complete marks the user's
+	// watermarks as fully loaded.
+	Advance(ctx context.Context, userID string, watermarks map[string]int64, complete bool) error
+	// Watermarks returns a user's cached watermarks by room, and false if they were never fully loaded.
+	Watermarks(ctx context.Context, userID string) (map[string]int64, bool, error)
+}
+
+// advanceReceiptsScript raises the fields of the hash in KEYS[1] to the values in ARGV[3..] (room,
+// ID pairs) and refreshes its expiry to ARGV[1] milliseconds. ARGV[2] = "1" marks it complete.
+var advanceReceiptsScript = goredis.NewScript(`
+for i = 3, #ARGV, 2 do
+  local current = tonumber(redis.call('HGET', KEYS[1], ARGV[i]))
+  if current == nil or current < tonumber(ARGV[i + 1]) then
+    redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+  end
+end
+if ARGV[2] == '1' then
+  redis.call('HSET', KEYS[1], '` + receiptCompleteField + `', '1')
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return 1
+`)
+
+// readReceiptCache is the Redis implementation of ReadReceiptCache: one HASH per user mapping
+// "room:<id>" fields to watermarks.
+type readReceiptCache struct {
+	client *goredis.Client
+	ttl    time.Duration
+}
+
+// NewReadReceiptCache creates a Redis backed ReadReceiptCache whose entries expire after ttl without writes.
+func NewReadReceiptCache(rc *RedisClient, ttl time.Duration) ReadReceiptCache {
+	return &readReceiptCache{client: rc.GetRawClient(), ttl: ttl}
+}
+
+func receiptsKey(userID string) string {
+	return fmt.Sprintf("receipts:user:%s", userID)
+}
+
+func (c *readReceiptCache) Advance(ctx context.Context, userID string, watermarks map[string]int64, complete bool) error {
+	flag := "0"
+	if complete {
+		flag = "1"
+	}
+	args := []interface{}{c.ttl.Milliseconds(), flag}
+	for roomID, id := range watermarks {
+		args = append(args, receiptRoomPrefix+roomID, id)
+	}
+	return advanceReceiptsScript.Run(ctx, c.client, []string{receiptsKey(userID)}, args...).Err()
+}
+
+func (c *readReceiptCache) Watermarks(ctx context.Context, userID string) (map[string]int64, bool, error) {
+	fields, err := c.client.HGetAll(ctx, receiptsKey(userID)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if fields[receiptCompleteField] == "" {
+		return nil, false, nil
+	}
+
+	watermarks := make(map[string]int64, len(fields))
+	for field, raw := range fields {
+		if !strings.HasPrefix(field, receiptRoomPrefix) {
+			continue
+		}
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, false, err
+		}
+		watermarks[strings.TrimPrefix(field, receiptRoomPrefix)] = id
+	}
+	return watermarks, true, nil
+}
@@ -0,0 +1,76 @@
+// redis/session_store.go
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"chat-websocket/model"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// SessionStore keeps the sessions of connections, and of closed connections for the resume window.
+type SessionStore interface {
+	// Save stores a session under its token until ttl passes.
+	Save(ctx context.Context, session *model.Session, ttl time.Duration) error
+	// Update replaces a stored session and its ttl, reporting false without storing anything if
+	// the session is no longer stored, e.g. because it was taken.
+	Update(ctx context.Context, session *model.Session, ttl time.Duration) (bool, error)
+	// Take removes and returns the session stored under token, or nil if there is none,
+	// so that a session is resumed at most once.
+	Take(ctx context.Context, token string) (*model.Session, error)
+}
+
+// sessionStore is the Redis implementation of SessionStore.
+type sessionStore struct {
+	client *goredis.Client
+}
+
+// NewSessionStore creates a Redis backed SessionStore.
+func NewSessionStore(rc *RedisClient) SessionStore {
+	return &sessionStore{client: rc.GetRawClient()}
+}
+
+func sessionKey(token string) string {
+	return fmt.Sprintf("session:%s", token)
+}
+
+func (s *sessionStore) Save(ctx context.Context, session *model.Session, ttl time.Duration) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, sessionKey(session.Token), data, ttl).Err()
+}
+
+func (s *sessionStore) Update(ctx context.Context, session *model.Session, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return false, err
+	}
+	return s.client.SetXX(ctx, sessionKey(session.Token), data, ttl).Result()
+}
+
+func (s *sessionStore) Take(ctx context.Context, token string) (*model.Session, error) {
+	var get *goredis.StringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		get = pipe.Get(ctx, sessionKey(token))
+		pipe.Del(ctx, sessionKey(token))
+		return nil
+	})
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session model.Session
+	if err := json.Unmarshal([]byte(get.Val()), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,326 @@
+// redis/stream.go
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chat-websocket/model"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+const (
+	// streamReadBlock bounds how long a newly subscribed topic waits to join the shared XREAD.
+	streamReadBlock  = 1 * time.Second
+	streamReadCount  = 100
+	streamRetryDelay = 1 * time.Second
+	// streamResumeAge bounds how old a saved cursor may be; older cursors start from the stream tip
+	// so a node that was gone for long does not flood newly joined clients with stale traffic.
+	streamResumeAge = 5 * time.Minute
+	// streamTopicTTL expires user and thread streams once idle; they are refreshed on every append.
+	// Room streams are capped by maxLen and kept.
+	streamTopicTTL = 1 * time.Hour
+	// streamStartSkew widens a new subscription's start back in time to cover clock error
+	// between the node and Redis, so nothing published after Subscribe returns is skipped.
+	streamStartSkew = 250 * time.Millisecond
+)
+
+// streamPubSubRepository implements PubSubRepository on Redis Streams.
+// Each topic is a capped stream; a single reader per node issues one XREAD over all local topics
+// from the node's last-seen IDs, which are persisted so that reconnects and restarts resume
+// without losing events.
+type streamPubSubRepository struct {
+	client  *goredis.Client
+	nodeID  string
+	maxLen  int64
+	mu      sync.Mutex
+	topics  map[string]*streamSubscription // Keyed by topic.
+	wake    chan struct{}
+	cancel  context.CancelFunc // Stops the reader; nil until the first Subscribe.
+	stopped chan struct{}
+}
+
+// streamSubscription is the handler and read position for one topic.
+// lastID is empty until the start position has been resolved.
+type streamSubscription struct {
+	handler func(*model.Event)
+	lastID  string
+}
+
+// NewStreamPubSubRepository creates a Redis Streams backed PubSubRepository.
+// maxLen caps each topic stream (approximately) to bound memory.
+func NewStreamPubSubRepository(rc *RedisClient, nodeID string, maxLen int64) PubSubRepository {
+	return &streamPubSubRepository{
+		client: rc.GetRawClient(),
+		nodeID: nodeID,
+		maxLen: maxLen,
+		topics: make(map[string]*streamSubscription),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// streamPrefix namespaces topic streams, e.g. "stream:room:lobby".
+const streamPrefix = "stream:"
+
+func streamKey(topic string) string {
+	return streamPrefix + topic
+}
+
+// cursorKey is the hash holding this node's last-seen stream ID per topic.
+func (r *streamPubSubRepository) cursorKey() string {
+	return "stream:cursor:" + r.nodeID
+}
+
+// Publish appends an event to the topic's stream.
+func (r *streamPubSubRepository) Publish(ctx context.Context, topic string, event *model.Event) error {
+	msgJSON, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal event: %v\n", err)
+		return err
+	}
+
+	key := streamKey(topic)
+	_, err = r.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.XAdd(ctx, &goredis.XAddArgs{
+			Stream: key,
+			MaxLen: r.maxLen,
+			Approx: true,
+			Values: map[string]interface{}{"event": msgJSON},
+		})
+		if !strings.HasPrefix(topic, RoomTopic("")) {
+			pipe.PExpire(ctx, key, streamTopicTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to append event to stream %s: %v\n", streamKey(topic), err)
+		return err
+	}
+	return nil
+}
+
+// Subscribe adds the topic's stream to the node's shared reader.
+// It makes no Redis round trips: the start position is resolved in the background, and the
+// stream joins the reader once it is known.
+// Each delivered event carries its stream ID in Seq, which orders events within the topic.
+func (r *streamPubSubRepository) Subscribe(ctx context.Context, topic string, handler func(*model.Event)) {
+	sub := &streamSubscription{handler: handler}
+
+	r.mu.Lock()
+	r.topics[topic] = sub
+	if r.cancel == nil {
+		readCtx, cancel := context.WithCancel(context.Background())
+		r.cancel = cancel
+		r.stopped = make(chan struct{})
+		go r.readLoop(readCtx, r.stopped)
+	}
+	r.mu.Unlock()
+
+	// Resolve only once the subscription is registered, so the result is never discarded as stale.
+	go r.resolveStart(topic, sub, time.Now())
+}
+
+// Unsubscribe removes the topic's stream from the shared reader and forgets this node's cursor for it.
+func (r *streamPubSubRepository) Unsubscribe(ctx context.Context, topic string) {
+	r.mu.Lock()
+	delete(r.topics, topic)
+	r.mu.Unlock()
+
+	if err := r.client.HDel(ctx, r.cursorKey(), topic).Err(); err != nil {
+		log.Printf("Failed to clear cursor for stream %s: %v\n", streamKey(topic), err)
+	}
+}
+
+// Close stops the shared reader and waits for it to exit. Saved cursors are kept so a restart resumes.
+func (r *streamPubSubRepository) Close() error {
+	r.mu.Lock()
+	cancel, stopped := r.cancel, r.stopped
+	r.cancel = nil
+	r.topics = make(map[string]*streamSubscription)
+	r.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-stopped
+	}
+	return nil
+}
+
+// readLoop issues one XREAD over every subscribed topic until ctx is cancelled.
+func (r *streamPubSubRepository) readLoop(ctx context.Context, stopped chan struct{}) {
+	defer close(stopped)
+
+	for ctx.Err() == nil {
+		args := r.readArgs()
+		if len(args) == 0 {
+			select {
+			case <-r.wake:
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		streams, err := r.client.XRead(ctx, &goredis.XReadArgs{
+			Streams: args,
+			Count:   streamReadCount,
+			Block:   streamReadBlock,
+		}).Result()
+		if errors.Is(err, goredis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("Failed to read streams, retrying from saved positions: %v", err)
+			time.Sleep(streamRetryDelay)
+			continue
+		}
+		r.deliver(streams)
+	}
+	log.Println("Redis Streams reader stopped.")
+}
+
+// readArgs builds the XREAD stream/ID list ("key1 key2 ... id1 id2 ...") for the subscribed topics.
+func (r *streamPubSubRepository) readArgs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]string, 0, len(r.topics))
+	ids := make([]string, 0, len(r.topics))
+	for topic, sub := range r.topics {
+		if sub.lastID == "" {
+			continue
+		}
+		keys = append(keys, streamKey(topic))
+		ids = append(ids, sub.lastID)
+	}
+	return append(keys, ids...)
+}
+
+// deliver hands each entry to its topic's handler, advancing and persisting the topic cursors.
+func (r *streamPubSubRepository) deliver(streams []goredis.XStream) {
+	cursors := make(map[string]interface{})
+	for _, stream := range streams {
+		topic := strings.TrimPrefix(stream.Stream, streamPrefix)
+		for _, msg := range stream.Messages {
+			r.mu.Lock()
+			sub := r.topics[topic]
+			if sub != nil {
+				sub.lastID = msg.ID
+			}
+			r.mu.Unlock()
+			if sub == nil {
+				// Unsubscribed while reading; drop the rest of this stream.
+				break
+			}
+			cursors[topic] = msg.ID
+
+			event, err := decodeStreamEvent(msg)
+			if err != nil {
+				log.Printf("Invalid event %s on stream %s: %v\n", msg.ID, stream.Stream, err)
+				continue
+			}
+			sub.handler(event)
+		}
+	}
+
+	// Only persist cursors for topics that are still subscribed.
+	r.mu.Lock()
+	for topic := range cursors {
+		if _, ok := r.topics[topic]; !ok {
+			delete(cursors, topic)
+		}
+	}
+	r.mu.Unlock()
+	if len(cursors) == 0 {
+		return
+	}
+	if err := r.client.HSet(context.Background(), r.cursorKey(), cursors).Err(); err != nil {
+		log.Printf("Failed to save stream cursors: %v", err)
+	}
+}
+
+// resolveStart sets a subscription's start position and wakes the reader, unless the topic
+// was unsubscribed or re-subscribed in the meantime. Until the position is known the topic is
+// left out of the reader; failures are retried.
+func (r *streamPubSubRepository) resolveStart(topic string, sub *streamSubscription, subscribedAt time.Time) {
+	for {
+		startID, err := r.startID(context.Background(), topic, subscribedAt)
+
+		r.mu.Lock()
+		current := r.topics[topic] == sub
+		if current && err == nil {
+			sub.lastID = startID
+		}
+		r.mu.Unlock()
+
+		if !current {
+			return
+		}
+		if err == nil {
+			select {
+			case r.wake <- struct{}{}:
+			default:
+			}
+			return
+		}
+		log.Printf("Failed to resolve start of stream %s, retrying: %v", streamKey(topic), err)
+		time.Sleep(streamRetryDelay)
+	}
+}
+
+// startID returns the ID to read after: the saved cursor if it is recent, otherwise the
+// position in Redis time at which the topic was subscribed.
+func (r *streamPubSubRepository) startID(ctx context.Context, topic string, subscribedAt time.Time) (string, error) {
+	saved, err := r.client.HGet(ctx, r.cursorKey(), topic).Result()
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return "", err
+	}
+	if err == nil && streamIDAge(saved) < streamResumeAge {
+		log.Printf("Resuming stream %s from %s", streamKey(topic), saved)
+		return saved, nil
+	}
+
+	// Stream IDs are stamped with Redis time, so map the subscription time onto it rather than
+	// reading the current tip, which would skip events appended while this was resolving.
+	now, err := r.client.Time(ctx).Result()
+	if err != nil {
+		return "", err
+	}
+	start := now.Add(-time.Since(subscribedAt) - streamStartSkew).UnixMilli()
+	if start < 0 {
+		start = 0
+	}
+	return strconv.FormatInt(start, 10) + "-0", nil
+}
+
+// streamIDAge returns how long ago the given stream ID was generated.
+func streamIDAge(id string) time.Duration {
+	ms, err := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64)
+	if err != nil {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Since(time.UnixMilli(ms))
+}
+
+// decodeStreamEvent decodes a stream entry and stamps it with its stream ID.
+func decodeStreamEvent(msg goredis.XMessage) (*model.Event, error) {
+	raw, ok := msg.Values["event"].(string)
+	if !ok {
+		return nil, errors.New("missing event field")
+	}
+	var event model.Event
+	if err := json.Unmarshal([]byte(raw), &event); err != nil {
+		return nil, err
+	}
+	event.Seq = msg.ID
+	return &event, nil
+}
@@ -0,0 +1,284 @@
+// redis/stream_test.go
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chat-websocket/model"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// fakeRedis is a minimal RESP server answering the commands the stream repository issues.
+type fakeRedis struct {
+	mu           sync.Mutex
+	cursor       string // Returned by HGET; empty for none.
+	timeFailures int    // TIME calls to fail before answering.
+	calls        [][]string
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, *goredis.Client) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	f := &fakeRedis{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	client := goredis.NewClient(&goredis.Options{Addr: ln.Addr().String(), MaxRetries: -1})
+	t.Cleanup(func() { _ = client.Close() })
+	return f, client
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, f.reply(args)); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) reply(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, args)
+
+	switch strings.ToUpper(args[0]) {
+	case "HGET":
+		if f.cursor == "" {
+			return "$-1\r\n"
+		}
+		return bulk(f.cursor)
+	case "TIME":
+		if f.timeFailures > 0 {
+			f.timeFailures--
+			return "-ERR unavailable\r\n"
+		}
+		now := time.Now()
+		return "*2\r\n" + bulk(strconv.FormatInt(now.Unix(), 10)) + bulk(strconv.Itoa(now.Nanosecond()/1000))
+	case "XREAD":
+		time.Sleep(20 * time.Millisecond)
+		return "*-1\r\n"
+	default:
+		return ":1\r\n"
+	}
+}
+
+// commands returns the recorded calls of the named command.
+func (f *fakeRedis) commands(name string) [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls [][]string
+	for _, args := range f.calls {
+		if strings.EqualFold(args[0], name) {
+			calls = append(calls, args)
+		}
+	}
+	return calls
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// lastIDOf returns the topic's read position, or "" if it is not resolved or not subscribed.
+func (r *streamPubSubRepository) lastIDOf(topic string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if sub := r.topics[topic]; sub != nil {
+		return sub.lastID
+	}
+	return ""
+}
+
+func TestStreamSubscribeResolvesStart(t *testing.T) {
+	recent := strconv.FormatInt(time.Now().Add(-time.Minute).UnixMilli(), 10) + "-3"
+	tests := []struct {
+		name         string
+		cursor       string
+		timeFailures int
+		want         string // Expected start; empty means the Redis time of the subscription.
+	}{
+		{name: "no saved cursor", want: ""},
+		{name: "recent saved cursor", cursor: recent, want: recent},
+		{name: "stale saved cursor", cursor: "1000-0", want: ""},
+		{name: "Redis time unavailable at first", timeFailures: 1, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeRedis(t)
+			fake.cursor, fake.timeFailures = tt.cursor, tt.timeFailures
+			repo := &streamPubSubRepository{
+				client: client,
+				nodeID: "test",
+				topics: make(map[string]*streamSubscription),
+				wake:   make(chan struct{}, 1),
+			}
+			t.Cleanup(func() { _ = repo.Close() })
+
+			subscribedAt := time.Now()
+			repo.Subscribe(context.Background(), RoomTopic("lobby"), func(*model.Event) {})
+
+			var got string
+			deadline := time.Now().Add(3 * streamRetryDelay)
+			for got == "" && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+				got = repo.lastIDOf(RoomTopic("lobby"))
+			}
+			if got == "" {
+				t.Fatalf("start position was never resolved")
+			}
+
+			if tt.want != "" {
+				if got != tt.want {
+					t.Errorf("start = %s, want %s", got, tt.want)
+				}
+			} else {
+				ms, err := strconv.ParseInt(strings.TrimSuffix(got, "-0"), 10, 64)
+				if err != nil {
+					t.Fatalf("start = %s, want a millisecond ID", got)
+				}
+				earliest := subscribedAt.Add(-streamStartSkew - 50*time.Millisecond).UnixMilli()
+				if ms < earliest || ms > subscribedAt.UnixMilli() {
+					t.Errorf("start = %s, want the subscription time %d less the skew", got, subscribedAt.UnixMilli())
+				}
+			}
+
+			for _, args := range fake.commands("XREAD") {
+				for _, arg := range args {
+					if arg == "0-0" {
+						t.Errorf("XREAD from the start of the stream: %v", args)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestStreamDeliver(t *testing.T) {
+	event := `{"v":1,"type":"message","room_id":"lobby","content":"hello"}`
+	tests := []struct {
+		name       string
+		subscribed bool
+		messages   []goredis.XMessage
+		wantSeqs   []string
+		wantLastID string
+	}{
+		{
+			name:       "delivered in order with their stream IDs",
+			subscribed: true,
+			messages: []goredis.XMessage{
+				{ID: "5-0", Values: map[string]interface{}{"event": event}},
+				{ID: "5-1", Values: map[string]interface{}{"event": event}},
+			},
+			wantSeqs:   []string{"5-0", "5-1"},
+			wantLastID: "5-1",
+		},
+		{
+			name:       "invalid entries are skipped but advance the cursor",
+			subscribed: true,
+			messages: []goredis.XMessage{
+				{ID: "5-0", Values: map[string]interface{}{"event": event}},
+				{ID: "6-0", Values: map[string]interface{}{"other": "x"}},
+			},
+			wantSeqs:   []string{"5-0"},
+			wantLastID: "6-0",
+		},
+		{
+			name:       "unsubscribed topics are dropped",
+			subscribed: false,
+			messages: []goredis.XMessage{
+				{ID: "5-0", Values: map[string]interface{}{"event": event}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeRedis(t)
+			repo := &streamPubSubRepository{
+				client: client,
+				nodeID: "test",
+				topics: make(map[string]*streamSubscription),
+			}
+			var seqs []string
+			if tt.subscribed {
+				repo.topics[RoomTopic("lobby")] = &streamSubscription{
+					handler: func(e *model.Event) { seqs = append(seqs, e.Seq) },
+					lastID:  "4-0",
+				}
+			}
+
+			repo.deliver([]goredis.XStream{{Stream: streamKey(RoomTopic("lobby")), Messages: tt.messages}})
+
+			if strings.Join(seqs, ",") != strings.Join(tt.wantSeqs, ",") {
+				t.Errorf("delivered %v, want %v", seqs, tt.wantSeqs)
+			}
+			if got := repo.lastIDOf(RoomTopic("lobby")); got != tt.wantLastID {
+				t.Errorf("cursor = %q, want %q", got, tt.wantLastID)
+			}
+			saved := fake.commands("HSET")
+			if tt.wantLastID == "" {
+				if len(saved) != 0 {
+					t.Errorf("saved cursors %v for an unsubscribed topic", saved)
+				}
+			} else if len(saved) != 1 || saved[0][len(saved[0])-1] != tt.wantLastID {
+				t.Errorf("saved cursors %v, want %s", saved, tt.wantLastID)
+			}
+		})
+	}
+}
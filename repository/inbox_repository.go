@@ -0,0 +1,58 @@
+// repository/inbox_repository.go
+package repository
+
+import (
+	"time"
+
+	"chat-websocket/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InboxRepository defines methods for accessing the offline inbox entries spilled out of Redis.
+type InboxRepository interface {
+	// SaveInboxEntries stores entries, skipping any already stored.
+	SaveInboxEntries(entries []model.InboxEntry) error
+	// ListInbox returns up to limit of a user's stored entries after afterID that were queued at or
+	// after since, in ascending ID order.
+	ListInbox(userID string, afterID int64, since time.Time, limit int) ([]model.InboxEntry, error)
+	// DeleteInbox removes a user's entries up to and including upToID.
+	DeleteInbox(userID string, upToID int64) error
+	// DeleteExpiredInbox removes up to limit entries of any user queued before the given time and
+	// returns how many it removed.
+	DeleteExpiredInbox(before time.Time, limit int) (int64, error)
+}
+
+// MysqlInboxRepository is the MySQL implementation of InboxRepository.
+type MysqlInboxRepository struct {
+	db *gorm.DB
+}
+
+// NewInboxRepository creates a new instance of MysqlInboxRepository.
+func NewInboxRepository(db *gorm.DB) InboxRepository {
+	return &MysqlInboxRepository{db: db}
+}
+
+func (r *MysqlInboxRepository) SaveInboxEntries(entries []model.InboxEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(entries, 500).Error
+}
+
+func (r *MysqlInboxRepository) ListInbox(userID string, afterID int64, since time.Time, limit int) ([]model.InboxEntry, error) {
+	entries := []model.InboxEntry{}
+	err := r.db.Where("user_id = ? AND id > ? AND created_at >= ?", userID, afterID, since).
+		Order("id ASC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+func (r *MysqlInboxRepository) DeleteInbox(userID string, upToID int64) error {
+	return r.db.Where("user_id = ? AND id <= ?", userID, upToID).Delete(&model.InboxEntry{}).Error
+}
+
+func (r *MysqlInboxRepository) DeleteExpiredInbox(before time.Time, limit int) (int64, error) {
+	result := r.db.Where("created_at < ?", before).Limit(limit).Delete(&model.InboxEntry{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,49 @@
+// repository/memory_client_repository.go
+package repository
+
+import (
+	"chat-websocket/model"
+	"fmt"
+	"sync"
+)
+
+// MemoryClientRepository is an in-memory ClientRepository for development and tests.
+type MemoryClientRepository struct {
+	mu      sync.RWMutex
+	clients map[string]*model.Client // Keyed by ClientID.
+}
+
+// NewMemoryClientRepository creates a new instance of MemoryClientRepository.
+func NewMemoryClientRepository() ClientRepository {
+	return &MemoryClientRepository{clients: make(map[string]*model.Client)}
+}
+
+func (r *MemoryClientRepository) CreateClient(client *model.Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.clients[client.ClientID]; exists {
+		return fmt.Errorf("client already exists: %s", client.ClientID)
+	}
+	r.clients[client.ClientID] = client
+	return nil
+}
+
+func (r *MemoryClientRepository) GetClientByID(clientID string) (*model.Client, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	client, ok := r.clients[clientID]
+	if !ok {
+		return nil, fmt.Errorf("client not found: %s", clientID)
+	}
+	return client, nil
+}
+
+func (r *MemoryClientRepository) UpdateClient(client *model.Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clients[client.ClientID] = client
+	return nil
+}
@@ -0,0 +1,89 @@
+// repository/memory_inbox_repository.go
+package repository
+
+import (
+	"chat-websocket/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryInboxRepository is an in-memory InboxRepository for development and tests.
+type MemoryInboxRepository struct {
+	mu      sync.RWMutex
+	entries map[string]map[int64]model.InboxEntry // User ID -> entry ID -> entry.
+}
+
+// NewMemoryInboxRepository creates a new instance of MemoryInboxRepository.
+func NewMemoryInboxRepository() InboxRepository {
+	return &MemoryInboxRepository{entries: make(map[string]map[int64]model.InboxEntry)}
+}
+
+func (r *MemoryInboxRepository) SaveInboxEntries(entries []model.InboxEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range entries {
+		if r.entries[e.UserID] == nil {
+			r.entries[e.UserID] = make(map[int64]model.InboxEntry)
+		}
+		if _, ok := r.entries[e.UserID][e.ID]; !ok {
+			r.entries[e.UserID][e.ID] = e
+		}
+	}
+	return nil
+}
+
+func (r *MemoryInboxRepository) ListInbox(userID string, afterID int64, since time.Time, limit int) ([]model.InboxEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]model.InboxEntry, 0, len(r.entries[userID]))
+	for _, e := range r.entries[userID] {
+		if e.ID > afterID && !e.CreatedAt.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (r *MemoryInboxRepository) DeleteInbox(userID string, upToID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id := range r.entries[userID] {
+		if id <= upToID {
+			delete(r.entries[userID], id)
+		}
+	}
+	if len(r.entries[userID]) == 0 {
+		delete(r.entries, userID)
+	}
+	return nil
+}
+
+func (r *MemoryInboxRepository) DeleteExpiredInbox(before time.Time, limit int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for userID, entries := range r.entries {
+		for id, e := range entries {
+			if deleted == int64(limit) {
+				return deleted, nil
+			}
+			if e.CreatedAt.Before(before) {
+				delete(entries, id)
+				deleted++
+			}
+		}
+		if len(entries) == 0 {
+			delete(r.entries, userID)
+		}
+	}
+	return deleted, nil
+}
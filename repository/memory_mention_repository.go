@@ -0,0 +1,77 @@
+// repository/memory_mention_repository.go
+package repository
+
+import (
+	"chat-websocket/model"
+	"sync"
+	"time"
+)
+
+// MemoryMentionRepository is an in-memory MentionRepository for development and tests.
+type MemoryMentionRepository struct {
+	mu       sync.RWMutex
+	mentions []model.Mention // Ordered by ID.
+	nextID   int64
+}
+
+// NewMemoryMentionRepository creates a new instance of MemoryMentionRepository.
+func NewMemoryMentionRepository() MentionRepository {
+	return &MemoryMentionRepository{nextID: 1}
+}
+
+func (r *MemoryMentionRepository) SaveMentions(mentions []model.Mention) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing := make(map[int64]map[string]bool)
+	for _, m := range r.mentions {
+		if existing[m.MessageID] == nil {
+			existing[m.MessageID] = make(map[string]bool)
+		}
+		existing[m.MessageID][m.UserID] = true
+	}
+	for i := range mentions {
+		m := &mentions[i]
+		if existing[m.MessageID][m.UserID] {
+			continue
+		}
+		if existing[m.MessageID] == nil {
+			existing[m.MessageID] = make(map[string]bool)
+		}
+		existing[m.MessageID][m.UserID] = true
+		m.ID = r.nextID
+		r.nextID++
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = time.Now()
+		}
+		r.mentions = append(r.mentions, *m)
+	}
+	return nil
+}
+
+func (r *MemoryMentionRepository) ListMentions(userID string, page MessagePage) ([]model.Mention, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []model.Mention
+	for _, m := range r.mentions {
+		if m.UserID != userID {
+			continue
+		}
+		if page.Before > 0 && m.ID >= page.Before {
+			continue
+		}
+		if page.After > 0 && m.ID <= page.After {
+			continue
+		}
+		matched = append(matched, m)
+	}
+	if page.Limit > 0 && len(matched) > page.Limit {
+		if page.After > 0 {
+			matched = matched[:page.Limit]
+		} else {
+			matched = matched[len(matched)-page.Limit:]
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,290 @@
+// repository/memory_message_repository.go
+package repository
+
+import (
+	"chat-websocket/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryMessageRepository is an in-memory MessageRepository for development and tests.
+type MemoryMessageRepository struct {
+	mu             sync.RWMutex
+	messages       []model.Message // Ordered by ID.
+	nextID         int64
+	byClient       map[string]int // Sender and client message ID -> index in messages.
+	edits          []model.MessageEdit
+	reactions      []model.MessageReaction // In insertion order.
+	nextReactionID int64
+}
+
+// NewMemoryMessageRepository creates a new instance of MemoryMessageRepository.
+func NewMemoryMessageRepository() MessageRepository {
+	return &MemoryMessageRepository{nextID: 1, byClient: make(map[string]int)}
+}
+
+func clientMsgKey(senderID, clientMsgID string) string {
+	return senderID + "\x00" + clientMsgID
+}
+
+func (r *MemoryMessageRepository) CreateMessage(msg *model.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if msg.ClientMsgID != "" {
+		if _, exists := r.byClient[clientMsgKey(msg.SenderID, msg.ClientMsgID)]; exists {
+			return ErrDuplicate
+		}
+		r.byClient[clientMsgKey(msg.SenderID, msg.ClientMsgID)] = len(r.messages)
+	}
+
+	msg.ID = r.nextID
+	r.nextID++
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	r.messages = append(r.messages, *msg)
+	if i, ok := r.indexOf(msg.ParentID); ok && msg.ParentID != 0 {
+		createdAt := msg.CreatedAt
+		r.messages[i].ReplyCount++
+		r.messages[i].LastReplyAt = &createdAt
+	}
+	return nil
+}
+
+func (r *MemoryMessageRepository) GetMessageByClientMsgID(senderID, clientMsgID string) (*model.Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i, ok := r.byClient[clientMsgKey(senderID, clientMsgID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	msg := r.messages[i]
+	return &msg, nil
+}
+
+func (r *MemoryMessageRepository) GetMessage(id int64) (*model.Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i, ok := r.indexOf(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	msg := r.messages[i]
+	return &msg, nil
+}
+
+func (r *MemoryMessageRepository) GetMessages(ids []int64) ([]model.Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var messages []model.Message
+	for _, id := range ids {
+		if i, ok := r.indexOf(id); ok {
+			messages = append(messages, r.messages[i])
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages, nil
+}
+
+func (r *MemoryMessageRepository) EditMessage(id int64, editorID, content string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.indexOf(id)
+	if !ok || r.messages[i].DeletedAt != nil {
+		return ErrNotFound
+	}
+	r.edits = append(r.edits, model.MessageEdit{
+		ID:              int64(len(r.edits) + 1),
+		MessageID:       id,
+		EditorID:        editorID,
+		PreviousContent: r.messages[i].Content,
+		EditedAt:        at,
+	})
+	r.messages[i].Content = content
+	r.messages[i].EditedAt = &at
+	return nil
+}
+
+func (r *MemoryMessageRepository) DeleteMessage(id int64, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.indexOf(id)
+	if !ok || r.messages[i].DeletedAt != nil {
+		return ErrNotFound
+	}
+	r.messages[i].DeletedAt = &at
+	return nil
+}
+
+func (r *MemoryMessageRepository) ListEdits(messageID int64) ([]model.MessageEdit, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	edits := []model.MessageEdit{}
+	for _, e := range r.edits {
+		if e.MessageID == messageID {
+			edits = append(edits, e)
+		}
+	}
+	return edits, nil
+}
+
+// indexOf finds a message by ID. The caller must hold r.mu.
+func (r *MemoryMessageRepository) indexOf(id int64) (int, bool) {
+	i := sort.Search(len(r.messages), func(i int) bool { return r.messages[i].ID >= id })
+	return i, i < len(r.messages) && r.messages[i].ID == id
+}
+
+func (r *MemoryMessageRepository) AddReaction(reaction *model.MessageReaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.reactions {
+		if existing.MessageID == reaction.MessageID && existing.UserID == reaction.UserID && existing.Emoji == reaction.Emoji {
+			return ErrDuplicate
+		}
+	}
+	r.nextReactionID++
+	reaction.ID = r.nextReactionID
+	if reaction.CreatedAt.IsZero() {
+		reaction.CreatedAt = time.Now()
+	}
+	r.reactions = append(r.reactions, *reaction)
+	return nil
+}
+
+func (r *MemoryMessageRepository) RemoveReaction(messageID int64, userID, emoji string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.reactions {
+		if existing.MessageID == messageID && existing.UserID == userID && existing.Emoji == emoji {
+			r.reactions = append(r.reactions[:i], r.reactions[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryMessageRepository) CountReactions(messageID int64, emoji string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, existing := range r.reactions {
+		if existing.MessageID == messageID && existing.Emoji == emoji {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// attachReactions fills in the reaction counts of messages, listing each message's emojis in
+// the order they were first used. The caller must hold r.mu.
+func (r *MemoryMessageRepository) attachReactions(messages []model.Message) {
+	byID := make(map[int64]*model.Message, len(messages))
+	for i := range messages {
+		byID[messages[i].ID] = &messages[i]
+	}
+	for _, reaction := range r.reactions {
+		msg := byID[reaction.MessageID]
+		if msg == nil {
+			continue
+		}
+		found := false
+		for i := range msg.Reactions {
+			if msg.Reactions[i].Emoji == reaction.Emoji {
+				msg.Reactions[i].Count++
+				found = true
+				break
+			}
+		}
+		if !found {
+			msg.Reactions = append(msg.Reactions, model.ReactionCount{Emoji: reaction.Emoji, Count: 1})
+		}
+	}
+}
+
+func (r *MemoryMessageRepository) CountUnread(userID string, watermarks map[string]int64) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int, len(watermarks))
+	for _, m := range r.messages {
+		lastReadID, ok := watermarks[m.RoomID]
+		if !ok || m.RoomID == "" || m.ID <= lastReadID || m.SenderID == userID || m.ParentID != 0 || m.DeletedAt != nil {
+			continue
+		}
+		counts[m.RoomID]++
+	}
+	return counts, nil
+}
+
+func (r *MemoryMessageRepository) GetMessagesByRoom(room string) ([]model.Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var messages []model.Message
+	for _, m := range r.messages {
+		if m.RoomID == room {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (r *MemoryMessageRepository) ListRoomMessages(roomID string, page MessagePage) ([]model.Message, error) {
+	return r.listPage(page, func(m *model.Message) bool {
+		return m.RoomID == roomID && m.ParentID == 0
+	}), nil
+}
+
+func (r *MemoryMessageRepository) ListReplies(parentID int64, page MessagePage) ([]model.Message, error) {
+	return r.listPage(page, func(m *model.Message) bool {
+		return m.ParentID == parentID
+	}), nil
+}
+
+func (r *MemoryMessageRepository) ListConversation(userA, userB string, page MessagePage) ([]model.Message, error) {
+	return r.listPage(page, func(m *model.Message) bool {
+		return (m.SenderID == userA && m.RecipientID == userB) || (m.SenderID == userB && m.RecipientID == userA)
+	}), nil
+}
+
+// listPage returns the matching messages within page, in ascending ID order.
+func (r *MemoryMessageRepository) listPage(page MessagePage, match func(*model.Message) bool) []model.Message {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []model.Message
+	for i := range r.messages {
+		m := &r.messages[i]
+		if !match(m) {
+			continue
+		}
+		if page.Before > 0 && m.ID >= page.Before {
+			continue
+		}
+		if page.After > 0 && m.ID <= page.After {
+			continue
+		}
+		matched = append(matched, *m)
+	}
+
+	if page.Limit > 0 && len(matched) > page.Limit {
+		if page.After > 0 {
+			matched = matched[:page.Limit]
+		} else {
+			matched = matched[len(matched)-page.Limit:]
+		}
+	}
+	r.attachReactions(matched)
+	return matched
+}
@@ -0,0 +1,47 @@
+// repository/memory_receipt_repository.go
+package repository
+
+import (
+	"chat-websocket/model"
+	"sync"
+	"time"
+)
+
+// MemoryReceiptRepository is an in-memory ReceiptRepository for development and tests.
+type MemoryReceiptRepository struct {
+	mu       sync.RWMutex
+	receipts map[string]map[string]model.ReadReceipt // User ID -> room ID -> watermark.
+}
+
+// NewMemoryReceiptRepository creates a new instance of MemoryReceiptRepository.
+func NewMemoryReceiptRepository() ReceiptRepository {
+	return &MemoryReceiptRepository{receipts: make(map[string]map[string]model.ReadReceipt)}
+}
+
+func (r *MemoryReceiptRepository) AdvanceReadReceipt(receipt *model.ReadReceipt) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rooms, ok := r.receipts[receipt.UserID]
+	if !ok {
+		rooms = make(map[string]model.ReadReceipt)
+		r.receipts[receipt.UserID] = rooms
+	}
+	if existing, exists := rooms[receipt.RoomID]; exists && existing.LastReadID >= receipt.LastReadID {
+		return false, nil
+	}
+	receipt.UpdatedAt = time.Now()
+	rooms[receipt.RoomID] = *receipt
+	return true, nil
+}
+
+func (r *MemoryReceiptRepository) ListReadReceipts(userID string) ([]model.ReadReceipt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var receipts []model.ReadReceipt
+	for _, receipt := range r.receipts[userID] {
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
@@ -0,0 +1,176 @@
+// repository/memory_room_repository.go
+package repository
+
+import (
+	"chat-websocket/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRoomRepository is an in-memory RoomRepository for development and tests.
+type MemoryRoomRepository struct {
+	mu          sync.RWMutex
+	rooms       map[string]model.RoomInfo
+	members     map[string]map[string]model.RoomMembership // Room ID -> user ID -> membership.
+	invitations map[string]map[string]model.RoomInvitation // Room ID -> invitee ID -> invitation.
+	nextID      int64
+}
+
+// NewMemoryRoomRepository creates a new instance of MemoryRoomRepository.
+func NewMemoryRoomRepository() RoomRepository {
+	return &MemoryRoomRepository{
+		rooms:       make(map[string]model.RoomInfo),
+		members:     make(map[string]map[string]model.RoomMembership),
+		invitations: make(map[string]map[string]model.RoomInvitation),
+		nextID:      1,
+	}
+}
+
+func (r *MemoryRoomRepository) CreateRoom(room *model.RoomInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.rooms[room.ID]; exists {
+		return ErrDuplicate
+	}
+	if room.CreatedAt.IsZero() {
+		room.CreatedAt = time.Now()
+	}
+	r.rooms[room.ID] = *room
+	r.addMemberLocked(model.RoomMembership{RoomID: room.ID, UserID: room.OwnerID, Role: model.RoleOwner})
+	return nil
+}
+
+func (r *MemoryRoomRepository) GetRoom(roomID string) (*model.RoomInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	room, ok := r.rooms[roomID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &room, nil
+}
+
+func (r *MemoryRoomRepository) GetMember(roomID, userID string) (*model.RoomMembership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	member, ok := r.members[roomID][userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &member, nil
+}
+
+func (r *MemoryRoomRepository) ListMembers(roomID string) ([]model.RoomMembership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	members := make([]model.RoomMembership, 0, len(r.members[roomID]))
+	for _, member := range r.members[roomID] {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID < members[j].UserID })
+	return members, nil
+}
+
+func (r *MemoryRoomRepository) ListMemberships(userID string) ([]model.RoomMembership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var members []model.RoomMembership
+	for _, roomMembers := range r.members {
+		if member, ok := roomMembers[userID]; ok {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].RoomID < members[j].RoomID })
+	return members, nil
+}
+
+func (r *MemoryRoomRepository) AddMember(member *model.RoomMembership) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.addMemberLocked(*member)
+	return nil
+}
+
+// addMemberLocked adds a membership unless one exists. The caller must hold r.mu.
+func (r *MemoryRoomRepository) addMemberLocked(member model.RoomMembership) {
+	members, ok := r.members[member.RoomID]
+	if !ok {
+		members = make(map[string]model.RoomMembership)
+		r.members[member.RoomID] = members
+	}
+	if _, exists := members[member.UserID]; exists {
+		return
+	}
+	if member.CreatedAt.IsZero() {
+		member.CreatedAt = time.Now()
+	}
+	members[member.UserID] = member
+}
+
+func (r *MemoryRoomRepository) SetRole(roomID, userID, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	member, ok := r.members[roomID][userID]
+	if !ok {
+		return ErrNotFound
+	}
+	member.Role = role
+	r.members[roomID][userID] = member
+	return nil
+}
+
+func (r *MemoryRoomRepository) RemoveMember(roomID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.members[roomID], userID)
+	return nil
+}
+
+func (r *MemoryRoomRepository) SaveInvitation(inv *model.RoomInvitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitations, ok := r.invitations[inv.RoomID]
+	if !ok {
+		invitations = make(map[string]model.RoomInvitation)
+		r.invitations[inv.RoomID] = invitations
+	}
+	if existing, exists := invitations[inv.InviteeID]; exists {
+		inv.ID = existing.ID
+	} else {
+		inv.ID = r.nextID
+		r.nextID++
+	}
+	inv.Status = model.InvitationPending
+	inv.AcceptedAt = nil
+	if inv.CreatedAt.IsZero() {
+		inv.CreatedAt = time.Now()
+	}
+	invitations[inv.InviteeID] = *inv
+	return nil
+}
+
+func (r *MemoryRoomRepository) AcceptInvitation(roomID, inviteeID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inv, ok := r.invitations[roomID][inviteeID]
+	if !ok || inv.Status != model.InvitationPending {
+		return ErrNotFound
+	}
+	now := time.Now()
+	inv.Status = model.InvitationAccepted
+	inv.AcceptedAt = &now
+	r.invitations[roomID][inviteeID] = inv
+	r.addMemberLocked(model.RoomMembership{RoomID: roomID, UserID: inviteeID, Role: model.RoleMember})
+	return nil
+}
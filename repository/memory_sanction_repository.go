@@ -0,0 +1,87 @@
+// repository/memory_sanction_repository.go
+package repository
+
+import (
+	"chat-websocket/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemorySanctionRepository is an in-memory SanctionRepository for development and tests.
+type MemorySanctionRepository struct {
+	mu        sync.RWMutex
+	sanctions map[string]model.RoomSanction // Keyed by sanctionKey.
+	nextID    int64
+}
+
+// NewMemorySanctionRepository creates a new instance of MemorySanctionRepository.
+func NewMemorySanctionRepository() SanctionRepository {
+	return &MemorySanctionRepository{
+		sanctions: make(map[string]model.RoomSanction),
+		nextID:    1,
+	}
+}
+
+func sanctionKey(roomID, userID, kind string) string {
+	return roomID + "\x00" + userID + "\x00" + kind
+}
+
+func (r *MemorySanctionRepository) SaveSanction(s *model.RoomSanction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := sanctionKey(s.RoomID, s.UserID, s.Kind)
+	if existing, exists := r.sanctions[key]; exists {
+		s.ID = existing.ID
+	} else {
+		s.ID = r.nextID
+		r.nextID++
+	}
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now()
+	}
+	r.sanctions[key] = *s
+	return nil
+}
+
+func (r *MemorySanctionRepository) GetActiveSanction(roomID, userID, kind string, now time.Time) (*model.RoomSanction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.sanctions[sanctionKey(roomID, userID, kind)]
+	if !ok || !s.Active(now) {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+func (r *MemorySanctionRepository) DeleteSanction(roomID, userID, kind string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := sanctionKey(roomID, userID, kind)
+	s, ok := r.sanctions[key]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(r.sanctions, key)
+	if !s.Active(now) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemorySanctionRepository) ListActiveSanctions(roomID string, now time.Time) ([]model.RoomSanction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sanctions := []model.RoomSanction{}
+	for _, s := range r.sanctions {
+		if s.RoomID == roomID && s.Active(now) {
+			sanctions = append(sanctions, s)
+		}
+	}
+	sort.Slice(sanctions, func(i, j int) bool { return sanctions[i].ID < sanctions[j].ID })
+	return sanctions, nil
+}
@@ -0,0 +1,57 @@
+// repository/mention_repository.go
+package repository
+
+import (
+	"chat-websocket/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MentionRepository defines methods for accessing message mentions.
+type MentionRepository interface {
+	// SaveMentions stores mentions, skipping users already mentioned by the same message.
+	SaveMentions(mentions []model.Mention) error
+	// ListMentions returns a keyset-paginated page of a user's mentions in ascending ID order.
+	ListMentions(userID string, page MessagePage) ([]model.Mention, error)
+}
+
+// MysqlMentionRepository is the MySQL implementation of MentionRepository.
+type MysqlMentionRepository struct {
+	db *gorm.DB
+}
+
+// NewMentionRepository creates a new instance of MysqlMentionRepository.
+func NewMentionRepository(db *gorm.DB) MentionRepository {
+	return &MysqlMentionRepository{db: db}
+}
+
+func (r *MysqlMentionRepository) SaveMentions(mentions []model.Mention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(mentions, 500).Error
+}
+
+func (r *MysqlMentionRepository) ListMentions(userID string, page MessagePage) ([]model.Mention, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if page.Before > 0 {
+		query = query.Where("id < ?", page.Before)
+	}
+	if page.After > 0 {
+		query = query.Where("id > ?", page.After)
+	}
+
+	var mentions []model.Mention
+	if page.After > 0 {
+		err := query.Order("id ASC").Limit(page.Limit).Find(&mentions).Error
+		return mentions, err
+	}
+	if err := query.Order("id DESC").Limit(page.Limit).Find(&mentions).Error; err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(mentions)-1; i < j; i, j = i+1, j-1 {
+		mentions[i], mentions[j] = mentions[j], mentions[i]
+	}
+	return mentions, nil
+}
@@ -3,14 +3,59 @@ package repository
 
 import (
 	"chat-websocket/model"
+	"errors"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// MessagePage selects a window of messages by ID. Before and After are exclusive bounds;
+// zero means unbounded. With no After bound the newest Limit messages are selected.
+type MessagePage struct {
+	Before int64
+	After  int64
+	Limit  int
+}
+
 // MessageRepository defines methods for accessing message data.
 type MessageRepository interface {
+	// CreateMessage stores a message, returning ErrDuplicate if its sender already stored one
+	// with the same ClientMsgID. Storing a reply updates the reply count and last reply time
+	// of its parent.
 	CreateMessage(msg *model.Message) error
+	// GetMessageByClientMsgID returns the message a sender stored under an idempotency key.
+	GetMessageByClientMsgID(senderID, clientMsgID string) (*model.Message, error)
+	GetMessage(id int64) (*model.Message, error)
+	// GetMessages returns the messages with the given IDs that exist, in ascending ID order.
+	GetMessages(ids []int64) ([]model.Message, error)
+	// EditMessage replaces the content of a message that is not deleted, recording the previous
+	// content in its edit history.
+	EditMessage(id int64, editorID, content string, at time.Time) error
+	// DeleteMessage soft-deletes a message, returning ErrNotFound if it is missing or already deleted.
+	DeleteMessage(id int64, at time.Time) error
+	// ListEdits returns a message's edit history, oldest first.
+	ListEdits(messageID int64) ([]model.MessageEdit, error)
+	// AddReaction stores a reaction, returning ErrDuplicate if the user already reacted to the
+	// message with the same emoji.
+	AddReaction(reaction *model.MessageReaction) error
+	// RemoveReaction deletes a user's reaction, returning ErrNotFound if there is none.
+	RemoveReaction(messageID int64, userID, emoji string) error
+	// CountReactions returns the number of users who reacted to a message with an emoji.
+	CountReactions(messageID int64, emoji string) (int, error)
+	// CountUnread counts, per room, the top-level messages that are not deleted, were not sent by
+	// userID and are newer than the room's watermark.
+	CountUnread(userID string, watermarks map[string]int64) (map[string]int, error)
 	GetMessagesByRoom(room string) ([]model.Message, error)
+	// ListRoomMessages returns a keyset-paginated page of a room's messages in ascending ID order,
+	// with their reaction counts.
+	ListRoomMessages(roomID string, page MessagePage) ([]model.Message, error)
+	// ListReplies returns a keyset-paginated page of the replies to a message in ascending ID
+	// order, with their reaction counts.
+	ListReplies(parentID int64, page MessagePage) ([]model.Message, error)
+	// ListConversation returns a keyset-paginated page of the direct messages exchanged
+	// between two users, in ascending ID order, with their reaction counts.
+	ListConversation(userA, userB string, page MessagePage) ([]model.Message, error)
 }
 
 // MysqlMessageRepository is the MySQL implementation of MessageRepository.
@@ -24,11 +69,241 @@ func NewMessageRepository(db *gorm.DB) MessageRepository {
 }
 
 func (r *MysqlMessageRepository) CreateMessage(msg *model.Message) error {
-	return r.db.Create(msg).Error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(msg).Error; err != nil {
+			return err
+		}
+		if msg.ParentID == 0 {
+			return nil
+		}
+		return tx.Model(&model.Message{}).Where("id = ?", msg.ParentID).
+			Updates(map[string]interface{}{
+				"reply_count":   gorm.Expr("reply_count + 1"),
+				"last_reply_at": msg.CreatedAt,
+			}).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *MysqlMessageRepository) GetMessageByClientMsgID(senderID, clientMsgID string) (*model.Message, error) {
+	var msg model.Message
+	err := r.db.Where("sender_id = ? AND client_msg_id = ?", senderID, clientMsgID).First(&msg).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &msg, nil
+}
+
+func (r *MysqlMessageRepository) GetMessage(id int64) (*model.Message, error) {
+	var msg model.Message
+	if err := r.db.Where("id = ?", id).First(&msg).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &msg, nil
+}
+
+func (r *MysqlMessageRepository) GetMessages(ids []int64) ([]model.Message, error) {
+	var messages []model.Message
+	if len(ids) == 0 {
+		return messages, nil
+	}
+	err := r.db.Where("id IN ?", ids).Order("id ASC").Find(&messages).Error
+	return messages, err
+}
+
+func (r *MysqlMessageRepository) EditMessage(id int64, editorID, content string, at time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var msg model.Message
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deleted_at IS NULL", id).
+			First(&msg).Error
+		if err != nil {
+			return notFound(err)
+		}
+		edit := &model.MessageEdit{MessageID: id, EditorID: editorID, PreviousContent: msg.Content, EditedAt: at}
+		if err := tx.Create(edit).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Message{}).Where("id = ?", id).
+			Updates(map[string]interface{}{"content": content, "edited_at": at}).Error
+	})
+}
+
+func (r *MysqlMessageRepository) DeleteMessage(id int64, at time.Time) error {
+	res := r.db.Model(&model.Message{}).
+		Where("id = ? AND deleted_at IS NULL", id).
+		Update("deleted_at", at)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MysqlMessageRepository) ListEdits(messageID int64) ([]model.MessageEdit, error) {
+	edits := []model.MessageEdit{}
+	err := r.db.Where("message_id = ?", messageID).Order("id ASC").Find(&edits).Error
+	return edits, err
+}
+
+func (r *MysqlMessageRepository) AddReaction(reaction *model.MessageReaction) error {
+	err := r.db.Create(reaction).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *MysqlMessageRepository) RemoveReaction(messageID int64, userID, emoji string) error {
+	res := r.db.Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
+		Delete(&model.MessageReaction{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MysqlMessageRepository) CountReactions(messageID int64, emoji string) (int, error) {
+	var count int64
+	err := r.db.Model(&model.MessageReaction{}).
+		Where("message_id = ? AND emoji = ?", messageID, emoji).
+		Count(&count).Error
+	return int(count), err
+}
+
+func (r *MysqlMessageRepository) CountUnread(userID string, watermarks map[string]int64) (map[string]int, error) {
+	counts := make(map[string]int, len(watermarks))
+	if len(watermarks) == 0 {
+		return counts, nil
+	}
+
+	// One grouped query over (room_id, id) ranges: room_id = ? AND id > ? per room.
+	var rooms *gorm.DB
+	for roomID, lastReadID := range watermarks {
+		if rooms == nil {
+			rooms = r.db.Where("room_id = ? AND id > ?", roomID, lastReadID)
+		} else {
+			rooms = rooms.Or("room_id = ? AND id > ?", roomID, lastReadID)
+		}
+	}
+	var rows []struct {
+		RoomID string
+		Count  int
+	}
+	err := r.db.Model(&model.Message{}).
+		Select("room_id, COUNT(*) AS count").
+		Where("sender_id <> ? AND parent_id IS NULL AND deleted_at IS NULL", userID).
+		Where(rooms).
+		Group("room_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.RoomID] = row.Count
+	}
+	return counts, nil
 }
 
 func (r *MysqlMessageRepository) GetMessagesByRoom(room string) ([]model.Message, error) {
 	var messages []model.Message
-	err := r.db.Where("room = ?", room).Order("created_at ASC").Find(&messages).Error
+	err := r.db.Where("room_id = ?", room).Order("id ASC").Find(&messages).Error
 	return messages, err
 }
+
+func (r *MysqlMessageRepository) ListRoomMessages(roomID string, page MessagePage) ([]model.Message, error) {
+	messages, err := listPage(r.db.Where("room_id = ? AND parent_id IS NULL", roomID), page)
+	if err != nil {
+		return nil, err
+	}
+	return messages, r.attachReactions(messages)
+}
+
+func (r *MysqlMessageRepository) ListReplies(parentID int64, page MessagePage) ([]model.Message, error) {
+	messages, err := listPage(r.db.Where("parent_id = ?", parentID), page)
+	if err != nil {
+		return nil, err
+	}
+	return messages, r.attachReactions(messages)
+}
+
+func (r *MysqlMessageRepository) ListConversation(userA, userB string, page MessagePage) ([]model.Message, error) {
+	query := r.db.Where(
+		"((sender_id = ? AND recipient_id = ?) OR (sender_id = ? AND recipient_id = ?))",
+		userA, userB, userB, userA,
+	)
+	messages, err := listPage(query, page)
+	if err != nil {
+		return nil, err
+	}
+	return messages, r.attachReactions(messages)
+}
+
+// attachReactions fills in the reaction counts of messages with one grouped query, listing
+// each message's emojis in the order they were first used.
+func (r *MysqlMessageRepository) attachReactions(messages []model.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(messages))
+	byID := make(map[int64]*model.Message, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].ID
+		byID[messages[i].ID] = &messages[i]
+	}
+
+	var rows []struct {
+		MessageID int64
+		Emoji     string
+		Count     int
+	}
+	err := r.db.Model(&model.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count").
+		Where("message_id IN ?", ids).
+		Group("message_id, emoji").
+		Order("MIN(id) ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if msg := byID[row.MessageID]; msg != nil {
+			msg.Reactions = append(msg.Reactions, model.ReactionCount{Emoji: row.Emoji, Count: row.Count})
+		}
+	}
+	return nil
+}
+
+// listPage applies keyset pagination on id to query and returns the page in ascending ID order.
+func listPage(query *gorm.DB, page MessagePage) ([]model.Message, error) {
+	if page.Before > 0 {
+		query = query.Where("id < ?", page.Before)
+	}
+	if page.After > 0 {
+		query = query.Where("id > ?", page.After)
+	}
+
+	var messages []model.Message
+	if page.After > 0 {
+		// Walking forward from a known message: oldest first.
+		err := query.Order("id ASC").Limit(page.Limit).Find(&messages).Error
+		return messages, err
+	}
+
+	// Walking backward (or loading the tail): newest first, then flip to ascending.
+	if err := query.Order("id DESC").Limit(page.Limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
@@ -0,0 +1,44 @@
+// repository/receipt_repository.go
+package repository
+
+import (
+	"chat-websocket/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReceiptRepository defines methods for accessing read watermarks.
+type ReceiptRepository interface {
+	// AdvanceReadReceipt raises a user's watermark in a room to receipt.LastReadID, creating it if
+	// needed. It reports false if the watermark was already at or beyond that ID.
+	AdvanceReadReceipt(receipt *model.ReadReceipt) (bool, error)
+	// ListReadReceipts returns all of a user's watermarks.
+	ListReadReceipts(userID string) ([]model.ReadReceipt, error)
+}
+
+// MysqlReceiptRepository is the MySQL implementation of ReceiptRepository.
+type MysqlReceiptRepository struct {
+	db *gorm.DB
+}
+
+// NewReceiptRepository creates a new instance of MysqlReceiptRepository.
+func NewReceiptRepository(db *gorm.DB) ReceiptRepository {
+	return &MysqlReceiptRepository{db: db}
+}
+
+func (r *MysqlReceiptRepository) AdvanceReadReceipt(receipt *model.ReadReceipt) (bool, error) {
+	res := r.db.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"last_read_id": gorm.Expr("GREATEST(last_read_id, VALUES(last_read_id))"),
+		}),
+	}).Create(receipt)
+	// MySQL counts 1 for an insert, 2 for a changed row and 0 for an unchanged one.
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *MysqlReceiptRepository) ListReadReceipts(userID string) ([]model.ReadReceipt, error) {
+	var receipts []model.ReadReceipt
+	err := r.db.Where("user_id = ?", userID).Find(&receipts).Error
+	return receipts, err
+}
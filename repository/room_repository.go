@@ -0,0 +1,156 @@
+// repository/room_repository.go
+package repository
+
+import (
+	"chat-websocket/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a record with the same key already exists.
+	ErrDuplicate = errors.New("record already exists")
+)
+
+// RoomRepository defines methods for accessing rooms, their members and invitations.
+type RoomRepository interface {
+	// CreateRoom stores a room together with its owner's membership, or returns ErrDuplicate.
+	CreateRoom(room *model.RoomInfo) error
+	GetRoom(roomID string) (*model.RoomInfo, error)
+	GetMember(roomID, userID string) (*model.RoomMembership, error)
+	// ListMembers returns the memberships of a room.
+	ListMembers(roomID string) ([]model.RoomMembership, error)
+	// ListMemberships returns the memberships of a user in all rooms.
+	ListMemberships(userID string) ([]model.RoomMembership, error)
+	// AddMember adds a membership; an existing membership keeps its role.
+	AddMember(member *model.RoomMembership) error
+	SetRole(roomID, userID, role string) error
+	// RemoveMember deletes a membership; removing a non-member is not an error.
+	RemoveMember(roomID, userID string) error
+	// SaveInvitation creates or renews the invitee's pending invitation into the room.
+	SaveInvitation(inv *model.RoomInvitation) error
+	// AcceptInvitation marks the invitee's pending invitation accepted and makes them a member.
+	AcceptInvitation(roomID, inviteeID string) error
+}
+
+// MysqlRoomRepository is the MySQL implementation of RoomRepository.
+type MysqlRoomRepository struct {
+	db *gorm.DB
+}
+
+// NewRoomRepository creates a new instance of MysqlRoomRepository.
+func NewRoomRepository(db *gorm.DB) RoomRepository {
+	return &MysqlRoomRepository{db: db}
+}
+
+func (r *MysqlRoomRepository) CreateRoom(room *model.RoomInfo) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(room)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrDuplicate
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.RoomMembership{
+			RoomID: room.ID,
+			UserID: room.OwnerID,
+			Role:   model.RoleOwner,
+		}).Error
+	})
+}
+
+func (r *MysqlRoomRepository) GetRoom(roomID string) (*model.RoomInfo, error) {
+	var room model.RoomInfo
+	if err := r.db.Where("id = ?", roomID).First(&room).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &room, nil
+}
+
+func (r *MysqlRoomRepository) GetMember(roomID, userID string) (*model.RoomMembership, error) {
+	var member model.RoomMembership
+	if err := r.db.Where("room_id = ? AND user_id = ?", roomID, userID).First(&member).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &member, nil
+}
+
+func (r *MysqlRoomRepository) ListMembers(roomID string) ([]model.RoomMembership, error) {
+	var members []model.RoomMembership
+	err := r.db.Where("room_id = ?", roomID).Order("user_id ASC").Find(&members).Error
+	return members, err
+}
+
+func (r *MysqlRoomRepository) ListMemberships(userID string) ([]model.RoomMembership, error) {
+	var members []model.RoomMembership
+	err := r.db.Where("user_id = ?", userID).Order("room_id ASC").Find(&members).Error
+	return members, err
+}
+
+func (r *MysqlRoomRepository) AddMember(member *model.RoomMembership) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(member).Error
+}
+
+func (r *MysqlRoomRepository) SetRole(roomID, userID, role string) error {
+	res := r.db.Model(&model.RoomMembership{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Update("role", role)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		// MySQL reports no affected rows when the role is unchanged, so confirm the membership exists.
+		if _, err := r.GetMember(roomID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MysqlRoomRepository) RemoveMember(roomID, userID string) error {
+	return r.db.Where("room_id = ? AND user_id = ?", roomID, userID).Delete(&model.RoomMembership{}).Error
+}
+
+func (r *MysqlRoomRepository) SaveInvitation(inv *model.RoomInvitation) error {
+	inv.Status = model.InvitationPending
+	if inv.CreatedAt.IsZero() {
+		inv.CreatedAt = time.Now()
+	}
+	return r.db.Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"inviter_id", "status", "created_at", "accepted_at"}),
+	}).Create(inv).Error
+}
+
+func (r *MysqlRoomRepository) AcceptInvitation(roomID, inviteeID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&model.RoomInvitation{}).
+			Where("room_id = ? AND invitee_id = ? AND status = ?", roomID, inviteeID, model.InvitationPending).
+			Updates(map[string]interface{}{"status": model.InvitationAccepted, "accepted_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.RoomMembership{
+			RoomID: roomID,
+			UserID: inviteeID,
+			Role:   model.RoleMember,
+		}).Error
+	})
+}
+
+// notFound maps gorm's record-not-found error to ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
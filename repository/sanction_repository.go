@@ -0,0 +1,76 @@
+// repository/sanction_repository.go
+package repository
+
+import (
+	"chat-websocket/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SanctionRepository defines methods for accessing room bans and mutes.
+type SanctionRepository interface {
+	// SaveSanction imposes a sanction, replacing any earlier one of the same kind on the user.
+	SaveSanction(s *model.RoomSanction) error
+	// GetActiveSanction returns the user's sanction of the given kind in force at now, or ErrNotFound.
+	GetActiveSanction(roomID, userID, kind string, now time.Time) (*model.RoomSanction, error)
+	// DeleteSanction lifts a sanction, returning ErrNotFound if none is in force.
+	DeleteSanction(roomID, userID, kind string, now time.Time) error
+	// ListActiveSanctions returns a room's sanctions in force at now.
+	ListActiveSanctions(roomID string, now time.Time) ([]model.RoomSanction, error)
+}
+
+// MysqlSanctionRepository is the MySQL implementation of SanctionRepository.
+type MysqlSanctionRepository struct {
+	db *gorm.DB
+}
+
+// NewSanctionRepository creates a new instance of MysqlSanctionRepository.
+func NewSanctionRepository(db *gorm.DB) SanctionRepository {
+	return &MysqlSanctionRepository{db: db}
+}
+
+func (r *MysqlSanctionRepository) SaveSanction(s *model.RoomSanction) error {
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now()
+	}
+	return r.db.Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"moderator_id", "reason", "expires_at", "created_at"}),
+	}).Create(s).Error
+}
+
+func (r *MysqlSanctionRepository) GetActiveSanction(roomID, userID, kind string, now time.Time) (*model.RoomSanction, error) {
+	var s model.RoomSanction
+	err := active(r.db, now).
+		Where("room_id = ? AND user_id = ? AND kind = ?", roomID, userID, kind).
+		First(&s).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &s, nil
+}
+
+func (r *MysqlSanctionRepository) DeleteSanction(roomID, userID, kind string, now time.Time) error {
+	res := active(r.db, now).
+		Where("room_id = ? AND user_id = ? AND kind = ?", roomID, userID, kind).
+		Delete(&model.RoomSanction{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MysqlSanctionRepository) ListActiveSanctions(roomID string, now time.Time) ([]model.RoomSanction, error) {
+	var sanctions []model.RoomSanction
+	err := active(r.db, now).Where("room_id = ?", roomID).Order("id ASC").Find(&sanctions).Error
+	return sanctions, err
+}
+
+// active restricts a sanction query to sanctions in force at now.
+func active(query *gorm.DB, now time.Time) *gorm.DB {
+	return query.Where("(expires_at IS NULL OR expires_at > ?)", now)
+}
@@ -5,13 +5,24 @@ import (
 	"context"
 	"log"
 
+	"chat-websocket/model"
 	"chat-websocket/redis"
 )
 
 // MessageService defines core message business logic.
 type MessageService interface {
 	SaveMessage(ctx context.Context, roomName, message string) error
-	BroadcastMessage(ctx context.Context, roomName, message string) error
+	// BroadcastMessage publishes a stored room message to its room, echoing it to the sender's
+	// devices that are not in the room.
+	BroadcastMessage(ctx context.Context, msg *model.Message) error
+	// SendDirectMessage publishes a stored direct message to the recipient's and the sender's devices.
+	SendDirectMessage(ctx context.Context, msg *model.Message) error
+	// BroadcastReply publishes a stored reply to its thread's subscribers and the sender's devices,
+	// and the parent's new reply count to the parent's room.
+	BroadcastReply(ctx context.Context, msg *model.Message, parent *model.Message) error
+	// PublishChange publishes an event about an existing message to the clients that can see it:
+	// the thread for replies, the room for other room messages, both participants for direct messages.
+	PublishChange(ctx context.Context, msg *model.Message, event *model.Event) error
 }
 
 type messageServiceImpl struct {
@@ -31,11 +42,72 @@ func (m *messageServiceImpl) SaveMessage(ctx context.Context, roomName, message
 	return nil
 }
 
-func (m *messageServiceImpl) BroadcastMessage(ctx context.Context, roomName, message string) error {
-	err := m.pubSubRepo.Publish(ctx, roomName, roomName+"|"+message)
+// BroadcastMessage publishes a stored message to its room as a message event.
+func (m *messageServiceImpl) BroadcastMessage(ctx context.Context, msg *model.Message) error {
+	err := m.pubSubRepo.Publish(ctx, redis.RoomTopic(msg.RoomID), model.NewMessageEvent(msg))
 	if err != nil {
-		log.Printf("Failed to broadcast message to room %s: %v", roomName, err)
+		log.Printf("Failed to broadcast message to room %s: %v", msg.RoomID, err)
 		return err
 	}
+	m.echo(ctx, msg)
+	return nil
+}
+
+// SendDirectMessage publishes a stored direct message to every connection of its recipient,
+// and of its sender so that the sender's other devices show it too.
+func (m *messageServiceImpl) SendDirectMessage(ctx context.Context, msg *model.Message) error {
+	err := m.pubSubRepo.Publish(ctx, redis.UserTopic(msg.RecipientID), model.NewMessageEvent(msg))
+	if err != nil {
+		log.Printf("Failed to send direct message to user %s: %v", msg.RecipientID, err)
+		return err
+	}
+	m.echo(ctx, msg)
+	return nil
+}
+
+// echo publishes a stored message to its sender's user topic. For room messages, the sender's
+// connections that receive the message through the room or thread skip the echo. The message
+// has been delivered by then, so a failure is only logged.
+func (m *messageServiceImpl) echo(ctx context.Context, msg *model.Message) {
+	if err := m.pubSubRepo.Publish(ctx, redis.UserTopic(msg.SenderID), model.NewMessageEvent(msg)); err != nil {
+		log.Printf("Failed to echo message %d to user %s: %v", msg.ID, msg.SenderID, err)
+	}
+}
+
+// BroadcastReply publishes a stored reply as a message event to its thread and its sender, and
+// a thread_updated event to the parent's room.
+func (m *messageServiceImpl) BroadcastReply(ctx context.Context, msg *model.Message, parent *model.Message) error {
+	err := m.pubSubRepo.Publish(ctx, redis.ThreadTopic(parent.ID), model.NewMessageEvent(msg))
+	if err != nil {
+		log.Printf("Failed to broadcast reply to thread %d: %v", parent.ID, err)
+		return err
+	}
+	m.echo(ctx, msg)
+
+	event := model.NewEvent(model.EventThreadUpdated, parent.RoomID, msg.SenderID)
+	event.ID = parent.ID
+	event.ReplyCount = parent.ReplyCount
+	event.LastReplyAt = parent.LastReplyAt
+	if err := m.pubSubRepo.Publish(ctx, redis.RoomTopic(parent.RoomID), event); err != nil {
+		log.Printf("Failed to publish thread update to room %s: %v", parent.RoomID, err)
+		return err
+	}
+	return nil
+}
+
+func (m *messageServiceImpl) PublishChange(ctx context.Context, msg *model.Message, event *model.Event) error {
+	topics := []string{redis.RoomTopic(msg.RoomID)}
+	switch {
+	case msg.RecipientID != "":
+		topics = []string{redis.UserTopic(msg.SenderID), redis.UserTopic(msg.RecipientID)}
+	case msg.ParentID != 0:
+		topics = []string{redis.ThreadTopic(msg.ParentID)}
+	}
+	for _, topic := range topics {
+		if err := m.pubSubRepo.Publish(ctx, topic, event); err != nil {
+			log.Printf("Failed to publish %s of message %d to %s: %v", event.Type, msg.ID, topic, err)
+			return err
+		}
+	}
 	return nil
 }
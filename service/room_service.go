@@ -5,12 +5,13 @@ import (
 	"context"
 	"log"
 
+	"chat-websocket/model"
 	"chat-websocket/redis"
 )
 
 // RoomService defines core room business logic.
 type RoomService interface {
-	BroadcastToRoom(ctx context.Context, roomName, message string) error
+	BroadcastToRoom(ctx context.Context, event *model.Event) error
 }
 
 type roomServiceImpl struct {
@@ -24,10 +25,11 @@ func NewRoomService(pubSubRepo redis.PubSubRepository) RoomService {
 	}
 }
 
-func (r *roomServiceImpl) BroadcastToRoom(ctx context.Context, roomName, message string) error {
-	err := r.pubSubRepo.Publish(ctx, roomName, roomName+"|"+message)
+// BroadcastToRoom publishes an event to the room named by event.RoomID.
+func (r *roomServiceImpl) BroadcastToRoom(ctx context.Context, event *model.Event) error {
+	err := r.pubSubRepo.Publish(ctx, redis.RoomTopic(event.RoomID), event)
 	if err != nil {
-		log.Printf("Failed to broadcast to room %s: %v", roomName, err)
+		log.Printf("Failed to broadcast to room %s: %v", event.RoomID, err)
 		return err
 	}
 	return nil
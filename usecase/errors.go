@@ -0,0 +1,39 @@
+// usecase/errors.go
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"chat-websocket/model"
+)
+
+// Error is a request failure that is reported back to the client, carrying one of the
+// model.ErrCode* codes.
+type Error struct {
+	Code       string
+	Message    string
+	RetryAfter time.Duration // Set for rate_limited errors.
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// newError creates an Error with a formatted message.
+func newError(code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// errInternal is reported for failures whose details should not reach the client.
+var errInternal = &Error{Code: model.ErrCodeInternal, Message: "internal error"}
+
+// AsError converts err to an *Error; unknown errors become an internal error.
+func AsError(err error) *Error {
+	var ue *Error
+	if errors.As(err, &ue) {
+		return ue
+	}
+	return errInternal
+}
@@ -0,0 +1,244 @@
+// usecase/inbox_usecase.go
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"chat-websocket/model"
+	"chat-websocket/redis"
+	"chat-websocket/repository"
+)
+
+const (
+	// inboxPageSize bounds the inbox entries sent to a connection before it acknowledges them.
+	inboxPageSize = 100
+	// inboxSweepInterval is how often entries past the inbox TTL are deleted from the database.
+	inboxSweepInterval = 10 * time.Minute
+	// inboxSweepBatch bounds the entries deleted per statement by a sweep.
+	inboxSweepBatch = 1000
+)
+
+// InboxUseCase queues the direct messages and mentions sent to offline users and delivers them
+// when the user reconnects, a page at a time. Entries stay queued until the client acknowledges
+// them, so delivery is at least once. Recent entries live in Redis; those beyond its cap spill
+// to the database. Either way an entry is kept for ttl after it was queued: expired entries are
+// skipped when reading, and Run deletes those that spilled.
+type InboxUseCase struct {
+	store    redis.InboxStore
+	repo     repository.InboxRepository
+	presence *PresenceUseCase
+	ttl      time.Duration
+
+	mutex      sync.Mutex
+	deliveries map[string]int64 // Client ID -> last inbox ID of the page awaiting acknowledgment.
+}
+
+// NewInboxUseCase creates a new InboxUseCase instance.
+func NewInboxUseCase(store redis.InboxStore, repo repository.InboxRepository, presence *PresenceUseCase, ttl time.Duration) *InboxUseCase {
+	return &InboxUseCase{
+		store:      store,
+		repo:       repo,
+		presence:   presence,
+		ttl:        ttl,
+		deliveries: make(map[string]int64),
+	}
+}
+
+// Run deletes the spilled entries past the inbox TTL until ctx is done. Every node sweeps;
+// the deletes are idempotent.
+func (iu *InboxUseCase) Run(ctx context.Context) {
+	ticker := time.NewTicker(inboxSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			iu.sweep(time.Now().Add(-iu.ttl))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sweep deletes the spilled entries queued before cutoff, a batch at a time.
+func (iu *InboxUseCase) sweep(cutoff time.Time) {
+	var total int64
+	for {
+		deleted, err := iu.repo.DeleteExpiredInbox(cutoff, inboxSweepBatch)
+		total += deleted
+		if err != nil {
+			log.Printf("[InboxUseCase] Failed to delete expired inbox entries: %v", err)
+			break
+		}
+		if deleted < inboxSweepBatch {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("[InboxUseCase] Deleted %d expired inbox entries", total)
+	}
+}
+
+// Offline reports whether a user has no live connection anywhere in the cluster. Callers check
+// before publishing and enqueue afterwards, so a user connecting in between gets the event twice
+// rather than never. When presence is unavailable the user is assumed offline.
+func (iu *InboxUseCase) Offline(ctx context.Context, userID string) bool {
+	_, conns, err := iu.presence.UserPresence(ctx, userID)
+	if err != nil {
+		log.Printf("[InboxUseCase] Failed to load presence of %s: %v", userID, err)
+		return true
+	}
+	return len(conns) == 0
+}
+
+// Enqueue adds an event to a user's inbox.
+func (iu *InboxUseCase) Enqueue(ctx context.Context, userID string, event *model.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[InboxUseCase] Failed to encode %s event for %s: %v", event.Type, userID, err)
+		return
+	}
+	id, overflow, err := iu.store.Push(ctx, userID, data)
+	if err != nil {
+		log.Printf("[InboxUseCase] Failed to queue %s event for %s: %v", event.Type, userID, err)
+		return
+	}
+	if len(overflow) > 0 {
+		if err := iu.repo.SaveInboxEntries(overflow); err != nil {
+			log.Printf("[InboxUseCase] Failed to spill %d inbox entries of %s: %v", len(overflow), userID, err)
+		}
+	}
+	log.Printf("[InboxUseCase] Queued %s event as inbox entry %d for %s", event.Type, id, userID)
+}
+
+// Deliver sends the first page of a newly connected client's inbox, if it is not empty.
+func (iu *InboxUseCase) Deliver(ctx context.Context, client *model.Client) {
+	iu.sendPage(ctx, client, 0)
+}
+
+// Disconnect forgets a closed client's delivery.
+func (iu *InboxUseCase) Disconnect(client *model.Client) {
+	iu.mutex.Lock()
+	delete(iu.deliveries, client.ID)
+	iu.mutex.Unlock()
+}
+
+// sendPage sends the client the page of its user's inbox after afterID, followed by an
+// inbox_done event reporting the last entry sent and whether more follow once it is acknowledged.
+// An empty inbox is not announced. The frames are queued without dropping: the client is
+// lossless until its last page is acknowledged, so an overflow disconnects it and the unacknowledged
+// entries are sent again on its next connection.
+func (iu *InboxUseCase) sendPage(ctx context.Context, client *model.Client, afterID int64) {
+	events, more, err := iu.Pending(ctx, client.SenderID, afterID, inboxPageSize)
+	if err != nil || (afterID == 0 && len(events) == 0) {
+		return
+	}
+
+	client.SetLossless(true)
+	var sent int
+	var lastID int64
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			continue
+		}
+		if !client.SendBlocking(data) {
+			return
+		}
+		sent++
+		lastID = event.InboxID
+	}
+
+	done := model.NewEvent(model.EventInboxDone, "", "")
+	_ = done.SetPayload(model.InboxPayload{Count: sent, LastID: lastID, More: more})
+	if data, err := json.Marshal(done); err == nil && !client.SendBlocking(data) {
+		return
+	}
+
+	iu.mutex.Lock()
+	if more {
+		iu.deliveries[client.ID] = lastID
+	} else {
+		delete(iu.deliveries, client.ID)
+	}
+	iu.mutex.Unlock()
+	log.Printf("[InboxUseCase] Delivered %d inbox events to client %s", sent, client.ID)
+}
+
+// Pending returns up to limit of the events queued for a user after afterID, oldest first, each
+// marked with its inbox ID, and whether more are queued. Entries past the inbox TTL are left out.
+func (iu *InboxUseCase) Pending(ctx context.Context, userID string, afterID int64, limit int) ([]*model.Event, bool, error) {
+	cutoff := time.Now().Add(-iu.ttl)
+	spilled, err := iu.repo.ListInbox(userID, afterID, cutoff, limit+1)
+	if err != nil {
+		log.Printf("[InboxUseCase] Failed to load spilled inbox of %s: %v", userID, err)
+		return nil, false, errInternal
+	}
+	recent, err := iu.store.List(ctx, userID)
+	if err != nil {
+		log.Printf("[InboxUseCase] Failed to load inbox of %s: %v", userID, err)
+		return nil, false, errInternal
+	}
+
+	// An entry popped by a concurrent push may briefly be in both places. The Redis list expires
+	// only after its last push, so older entries in it are checked against the TTL here.
+	entries := spilled
+	for _, entry := range recent {
+		if entry.ID > afterID && !entry.CreatedAt.Before(cutoff) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	events := make([]*model.Event, 0, limit)
+	var lastID int64
+	for _, entry := range entries {
+		if entry.ID == lastID {
+			continue
+		}
+		if len(events) == limit {
+			return events, true, nil
+		}
+		lastID = entry.ID
+		var event model.Event
+		if err := json.Unmarshal(entry.Event, &event); err != nil {
+			log.Printf("[InboxUseCase] Invalid inbox entry %d of %s: %v", entry.ID, userID, err)
+			continue
+		}
+		event.InboxID = entry.ID
+		events = append(events, &event)
+	}
+	return events, false, nil
+}
+
+// Ack removes a user's inbox entries up to and including upToID and, once the page the client
+// was sent has been acknowledged, sends the next one.
+func (iu *InboxUseCase) Ack(ctx context.Context, client *model.Client, upToID int64) error {
+	if upToID <= 0 {
+		return newError(model.ErrCodeInvalid, "inbox_id must be a positive inbox entry id")
+	}
+	userID := client.SenderID
+	if err := iu.store.Trim(ctx, userID, upToID); err != nil {
+		log.Printf("[InboxUseCase] Failed to trim inbox of %s: %v", userID, err)
+		return errInternal
+	}
+	if err := iu.repo.DeleteInbox(userID, upToID); err != nil {
+		log.Printf("[InboxUseCase] Failed to delete spilled inbox of %s: %v", userID, err)
+		return errInternal
+	}
+
+	iu.mutex.Lock()
+	lastID, delivering := iu.deliveries[client.ID]
+	iu.mutex.Unlock()
+	switch {
+	case delivering && upToID >= lastID:
+		iu.sendPage(ctx, client, lastID)
+	case !delivering:
+		client.SetLossless(false)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// usecase/inbox_usecase_test.go
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"chat-websocket/model"
+	"chat-websocket/redis"
+	"chat-websocket/repository"
+)
+
+// inboxPage reads the inbox events sent to the client up to the next inbox_done event and
+// returns their inbox IDs with the inbox_done payload. ok is false if no page arrives.
+func (tc *testClient) inboxPage(t *testing.T) (ids []int64, done model.InboxPayload, ok bool) {
+	t.Helper()
+	timeout := time.After(settle)
+	for {
+		select {
+		case event := <-tc.events:
+			if event.Type != model.EventInboxDone {
+				ids = append(ids, event.InboxID)
+				continue
+			}
+			if err := json.Unmarshal(event.Payload, &done); err != nil {
+				t.Fatalf("decode inbox_done payload: %v", err)
+			}
+			return ids, done, true
+		case <-timeout:
+			return ids, done, false
+		}
+	}
+}
+
+func TestInboxUseCasePaging(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	// 250 entries: the newest 100 stay in the store and the rest spill to the repository.
+	const queued = 250
+	for i := 0; i < queued; i++ {
+		env.inbox.Enqueue(ctx, "bob", model.NewEvent(model.EventMentioned, "lobby", "alice"))
+	}
+
+	bob := env.connect(t, "bob-1", "bob")
+	env.inbox.Deliver(ctx, bob.Client)
+
+	var next int64 = 1
+	for page := 1; ; page++ {
+		ids, done, ok := bob.inboxPage(t)
+		if !ok {
+			t.Fatalf("page %d was not sent", page)
+		}
+		want := int64(queued) - next + 1
+		if want > inboxPageSize {
+			want = inboxPageSize
+		}
+		if int64(len(ids)) != want || done.Count != len(ids) {
+			t.Fatalf("page %d has %d events (count %d), want %d", page, len(ids), done.Count, want)
+		}
+		for _, id := range ids {
+			if id != next {
+				t.Fatalf("page %d sent entry %d, want %d", page, id, next)
+			}
+			next++
+		}
+		if done.LastID != next-1 {
+			t.Errorf("page %d last_id = %d, want %d", page, done.LastID, next-1)
+		}
+		if done.More != (next <= queued) {
+			t.Errorf("page %d more = %v, want %v", page, done.More, next <= queued)
+		}
+
+		// Acknowledging part of the page does not send the next one.
+		if err := env.inbox.Ack(ctx, bob.Client, done.LastID-1); err != nil {
+			t.Fatalf("partial ack: %v", err)
+		}
+		if ids, _, ok := bob.inboxPage(t); ok || len(ids) > 0 {
+			t.Fatalf("partial ack of page %d sent %v", page, ids)
+		}
+		if err := env.inbox.Ack(ctx, bob.Client, done.LastID); err != nil {
+			t.Fatalf("ack: %v", err)
+		}
+		if !done.More {
+			break
+		}
+	}
+
+	pending, more, err := env.inbox.Pending(ctx, "bob", 0, inboxPageSize)
+	if err != nil || len(pending) != 0 || more {
+		t.Errorf("after the last ack: %d events pending, more %v, err %v", len(pending), more, err)
+	}
+}
+
+func TestInboxUseCaseExpiry(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryInboxRepository()
+	inbox := NewInboxUseCase(redis.NewMemoryInboxStore(100), repo, nil, time.Hour)
+
+	event := []byte(`{"v":1,"type":"mentioned","room_id":"lobby"}`)
+	now := time.Now()
+	_ = repo.SaveInboxEntries([]model.InboxEntry{
+		{ID: 1, UserID: "bob", Event: event, CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: 2, UserID: "bob", Event: event, CreatedAt: now.Add(-time.Minute)},
+	})
+
+	events, _, err := inbox.Pending(ctx, "bob", 0, inboxPageSize)
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(events) != 1 || events[0].InboxID != 2 {
+		t.Fatalf("pending %d events, want only entry 2", len(events))
+	}
+
+	inbox.sweep(now.Add(-time.Hour))
+	left, err := repo.ListInbox("bob", 0, time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("ListInbox: %v", err)
+	}
+	if len(left) != 1 || left[0].ID != 2 {
+		t.Errorf("entries left after the sweep = %v, want only entry 2", left)
+	}
+}
@@ -0,0 +1,259 @@
+// usecase/mention_usecase.go
+package usecase
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"chat-websocket/model"
+	"chat-websocket/pkg/metrics"
+	"chat-websocket/redis"
+	"chat-websocket/repository"
+)
+
+// maxMentionedUsers bounds the @user mentions honoured per message; @room and @here are
+// reserved for room owners and admins.
+const maxMentionedUsers = 20
+
+const (
+	// mentionQueueSize bounds the messages waiting for fan-out; Notify drops messages while it is full.
+	mentionQueueSize = 1024
+	// mentionWorkers fan out mentions concurrently, so that one large @room does not hold up the rest.
+	mentionWorkers = 4
+	// mentionBatchSize bounds the mentions saved and delivered per batch.
+	mentionBatchSize = 100
+)
+
+// MentionUseCase records the users mentioned by room messages and notifies them on every node,
+// whether or not they are in the room. Recipients are resolved and notified by background
+// workers, off the sender's connection.
+type MentionUseCase struct {
+	repo       repository.MentionRepository
+	messages   repository.MessageRepository
+	rooms      repository.RoomRepository
+	access     *RoomAccessUseCase
+	presence   *PresenceUseCase
+	inbox      *InboxUseCase
+	pubSubRepo redis.PubSubRepository
+	queue      chan mentionJob
+}
+
+// mentionJob is a stored room message waiting for its mentions to be fanned out.
+type mentionJob struct {
+	msg        *model.Message
+	users      []string
+	room, here bool
+}
+
+// NewMentionUseCase creates a new MentionUseCase instance. Mentions are fanned out once Run is started.
+func NewMentionUseCase(repo repository.MentionRepository, messages repository.MessageRepository, rooms repository.RoomRepository, access *RoomAccessUseCase, presence *PresenceUseCase, inbox *InboxUseCase, pubSubRepo redis.PubSubRepository) *MentionUseCase {
+	return &MentionUseCase{
+		repo:       repo,
+		messages:   messages,
+		rooms:      rooms,
+		access:     access,
+		presence:   presence,
+		inbox:      inbox,
+		pubSubRepo: pubSubRepo,
+		queue:      make(chan mentionJob, mentionQueueSize),
+	}
+}
+
+// Run fans out queued mentions until ctx is done.
+func (mu *MentionUseCase) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < mentionWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case job := <-mu.queue:
+					mu.fanOut(ctx, job)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Notify queues the mentions of a stored room message. Each mentioned user is stored and sent a
+// mentioned event, which is queued for users who are offline. @user mentions count only for
+// members of the room, @room reaches every member and @here the members present in the room;
+// @room and @here are honoured only from owners and admins. The sender is never notified.
+// Notify never blocks the sender's connection: while the queue is full, as it stays once Run
+// has stopped, the mentions are dropped and counted.
+func (mu *MentionUseCase) Notify(ctx context.Context, msg *model.Message) {
+	if msg.RoomID == "" {
+		return
+	}
+	users, room, here := model.ParseMentions(msg.Content)
+	if (room || here) && !mu.canMentionAll(msg.RoomID, msg.SenderID) {
+		room, here = false, false
+	}
+	if len(users) == 0 && !room && !here {
+		return
+	}
+	if len(users) > maxMentionedUsers {
+		users = users[:maxMentionedUsers]
+	}
+
+	select {
+	case mu.queue <- mentionJob{msg: msg, users: users, room: room, here: here}:
+	default:
+		metrics.MentionsDropped.Inc()
+		log.Printf("[MentionUseCase] Dropped mentions of message %d: mention queue is full", msg.ID)
+	}
+}
+
+// canMentionAll reports whether the user may use @room and @here in a room.
+func (mu *MentionUseCase) canMentionAll(roomID, userID string) bool {
+	member, err := mu.rooms.GetMember(roomID, userID)
+	return err == nil && model.CanManage(member.Role)
+}
+
+// fanOut resolves the users mentioned by a message, then stores and delivers their mentions in batches.
+func (mu *MentionUseCase) fanOut(ctx context.Context, job mentionJob) {
+	msg := job.msg
+	var mentions []model.Mention
+	mentioned := make(map[string]bool)
+	add := func(userID, kind string) {
+		if userID == msg.SenderID || mentioned[userID] {
+			return
+		}
+		mentioned[userID] = true
+		mentions = append(mentions, model.Mention{
+			MessageID: msg.ID,
+			RoomID:    msg.RoomID,
+			UserID:    userID,
+			SenderID:  msg.SenderID,
+			Kind:      kind,
+		})
+	}
+
+	for _, userID := range job.users {
+		if _, err := mu.rooms.GetMember(msg.RoomID, userID); err == nil {
+			add(userID, model.MentionUser)
+		}
+	}
+	if job.room {
+		members, err := mu.rooms.ListMembers(msg.RoomID)
+		if err != nil {
+			log.Printf("[MentionUseCase] Failed to list members of room %s: %v", msg.RoomID, err)
+		}
+		for _, m := range members {
+			add(m.UserID, model.MentionRoom)
+		}
+	}
+	if job.here {
+		present, err := mu.presence.RoomMembers(ctx, msg.RoomID)
+		if err != nil {
+			log.Printf("[MentionUseCase] Failed to load presence in room %s: %v", msg.RoomID, err)
+		}
+		for _, m := range present {
+			add(m.UserID, model.MentionHere)
+		}
+	}
+
+	for start := 0; start < len(mentions); start += mentionBatchSize {
+		end := start + mentionBatchSize
+		if end > len(mentions) {
+			end = len(mentions)
+		}
+		mu.deliver(ctx, msg, mentions[start:end])
+	}
+	if len(mentions) > 0 {
+		log.Printf("[MentionUseCase] Message %d mentioned %d users", msg.ID, len(mentions))
+	}
+}
+
+// deliver saves a batch of mentions of a message and notifies the mentioned users.
+func (mu *MentionUseCase) deliver(ctx context.Context, msg *model.Message, mentions []model.Mention) {
+	if err := mu.repo.SaveMentions(mentions); err != nil {
+		log.Printf("[MentionUseCase] Failed to save mentions of message %d: %v", msg.ID, err)
+	}
+	for _, m := range mentions {
+		event := model.NewMessageEvent(msg)
+		event.Type = model.EventMentioned
+		_ = event.SetPayload(model.MentionPayload{Kind: m.Kind})
+		offline := mu.inbox.Offline(ctx, m.UserID)
+		err := mu.pubSubRepo.Publish(ctx, redis.UserTopic(m.UserID), event)
+		if err != nil {
+			log.Printf("[MentionUseCase] Failed to notify %s of message %d: %v", m.UserID, msg.ID, err)
+		}
+		if offline || err != nil {
+			mu.inbox.Enqueue(ctx, m.UserID, event)
+		}
+	}
+}
+
+// ListMentions returns a page of the user's mentions with their messages, paginated like
+// MessageUseCase.GetRoomHistory. Deleted messages are served as tombstones, and mentions in rooms
+// the user may no longer read are left out.
+func (mu *MentionUseCase) ListMentions(ctx context.Context, userID string, page repository.MessagePage) ([]model.Mention, bool, error) {
+	if page.Limit <= 0 {
+		page.Limit = defaultHistoryLimit
+	}
+	if page.Limit > maxHistoryLimit {
+		page.Limit = maxHistoryLimit
+	}
+	limit := page.Limit
+
+	page.Limit++
+	mentions, err := mu.repo.ListMentions(userID, page)
+	if err != nil {
+		log.Printf("[MentionUseCase] Failed to load mentions of %s: %v", userID, err)
+		return nil, false, errInternal
+	}
+	hasMore := len(mentions) > limit
+	if hasMore {
+		if page.After > 0 {
+			mentions = mentions[:limit]
+		} else {
+			mentions = mentions[len(mentions)-limit:]
+		}
+	}
+	mentions = mu.readable(ctx, userID, mentions)
+
+	ids := make([]int64, len(mentions))
+	for i, m := range mentions {
+		ids[i] = m.MessageID
+	}
+	messages, err := mu.messages.GetMessages(ids)
+	if err != nil {
+		log.Printf("[MentionUseCase] Failed to load mentioned messages of %s: %v", userID, err)
+		return nil, false, errInternal
+	}
+	byID := make(map[int64]*model.Message, len(messages))
+	for i := range messages {
+		messages[i].Redact()
+		byID[messages[i].ID] = &messages[i]
+	}
+	for i := range mentions {
+		mentions[i].Message = byID[mentions[i].MessageID]
+	}
+	if mentions == nil {
+		mentions = []model.Mention{}
+	}
+	return mentions, hasMore, nil
+}
+
+// readable filters out the mentions in rooms the user may not read, checking each room once.
+func (mu *MentionUseCase) readable(ctx context.Context, userID string, mentions []model.Mention) []model.Mention {
+	allowed := make(map[string]bool)
+	result := mentions[:0]
+	for _, m := range mentions {
+		ok, checked := allowed[m.RoomID]
+		if !checked {
+			ok = mu.access.AuthorizeRead(ctx, m.RoomID, userID) == nil
+			allowed[m.RoomID] = ok
+		}
+		if ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+// usecase/mention_usecase_test.go
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"chat-websocket/model"
+	"chat-websocket/repository"
+)
+
+func newTestMentionUseCase(env *testEnv) *MentionUseCase {
+	return NewMentionUseCase(repository.NewMemoryMentionRepository(), repository.NewMemoryMessageRepository(),
+		env.roomRepo, env.access, env.presence, env.inbox, env.pubSub)
+}
+
+func TestMentionUseCaseNotifyDoesNotBlock(t *testing.T) {
+	env := newTestEnv(t)
+	mentions := newTestMentionUseCase(env)
+	msg := &model.Message{ID: 1, RoomID: "lobby", SenderID: "alice", Content: "hi @bob"}
+
+	// Run is never started, so the queue fills up as it does once the workers have stopped.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < mentionQueueSize+10; i++ {
+			mentions.Notify(context.Background(), msg)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Notify blocked on a full queue")
+	}
+	if got := len(mentions.queue); got != mentionQueueSize {
+		t.Errorf("queued %d messages, want %d", got, mentionQueueSize)
+	}
+}
+
+func TestMentionUseCaseListMentionsChecksAccess(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(env *testEnv)
+		wantRooms []string
+	}{
+		{
+			name:      "member of the private room",
+			setup:     func(env *testEnv) {},
+			wantRooms: []string{"lobby", "secret"},
+		},
+		{
+			name: "removed from the private room",
+			setup: func(env *testEnv) {
+				_ = env.roomRepo.RemoveMember("secret", "bob")
+			},
+			wantRooms: []string{"lobby"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			env := newTestEnv(t)
+			mentions := newTestMentionUseCase(env)
+			if _, err := env.access.CreateRoom(ctx, "secret", "alice", model.VisibilityPrivate); err != nil {
+				t.Fatalf("create room: %v", err)
+			}
+			_ = env.roomRepo.AddMember(&model.RoomMembership{RoomID: "secret", UserID: "bob", Role: model.RoleMember})
+			_ = mentions.repo.SaveMentions([]model.Mention{
+				{MessageID: 1, RoomID: "lobby", UserID: "bob", SenderID: "alice", Kind: model.MentionUser},
+				{MessageID: 2, RoomID: "secret", UserID: "bob", SenderID: "alice", Kind: model.MentionUser},
+			})
+			tt.setup(env)
+
+			got, _, err := mentions.ListMentions(ctx, "bob", repository.MessagePage{})
+			if err != nil {
+				t.Fatalf("ListMentions: %v", err)
+			}
+			var rooms []string
+			for _, m := range got {
+				rooms = append(rooms, m.RoomID)
+			}
+			if strings.Join(rooms, ",") != strings.Join(tt.wantRooms, ",") {
+				t.Errorf("mentions in rooms %v, want %v", rooms, tt.wantRooms)
+			}
+		})
+	}
+}
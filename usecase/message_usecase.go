@@ -6,37 +6,480 @@ import (
 	"chat-websocket/repository"
 	"chat-websocket/service"
 	"context"
+	"errors"
 	"log"
+	"time"
 )
 
 // MessageUseCase encapsulates higher-level message processing logic.
 type MessageUseCase struct {
 	MessageRepo    repository.MessageRepository
 	MessageService service.MessageService
+	Access         *RoomAccessUseCase
+	Inbox          *InboxUseCase
 }
 
 // NewMessageUseCase creates a new instance of MessageUseCase.
-func NewMessageUseCase(repo repository.MessageRepository, service service.MessageService) *MessageUseCase {
+func NewMessageUseCase(repo repository.MessageRepository, service service.MessageService, access *RoomAccessUseCase, inbox *InboxUseCase) *MessageUseCase {
 	return &MessageUseCase{
 		MessageRepo:    repo,
 		MessageService: service,
+		Access:         access,
+		Inbox:          inbox,
 	}
 }
 
-// ProcessMessage processes an incoming message: it saves the message to the DB and broadcasts it.
-func (mu *MessageUseCase) ProcessMessage(ctx context.Context, msg model.Message) {
-	// Save the message to the database.
-	if err := mu.MessageRepo.CreateMessage(&msg); err != nil {
-		log.Printf("[MessageUseCase] Failed to save message: %v\n", err)
-	} else {
-		log.Printf("[MessageUseCase] Message saved successfully.")
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// GetRoomHistory returns a page of a room's messages in ascending ID order and whether more
+// messages exist beyond the page in the direction of travel.
+func (mu *MessageUseCase) GetRoomHistory(ctx context.Context, roomID string, page repository.MessagePage) ([]model.Message, bool, error) {
+	messages, hasMore, err := fetchPage(page, func(p repository.MessagePage) ([]model.Message, error) {
+		return mu.MessageRepo.ListRoomMessages(roomID, p)
+	})
+	if err != nil {
+		log.Printf("[MessageUseCase] Failed to load history for room %s: %v\n", roomID, err)
+	}
+	return messages, hasMore, err
+}
+
+// GetConversationHistory returns a page of the direct messages between userID and peerID,
+// paginated like GetRoomHistory.
+func (mu *MessageUseCase) GetConversationHistory(ctx context.Context, userID, peerID string, page repository.MessagePage) ([]model.Message, bool, error) {
+	messages, hasMore, err := fetchPage(page, func(p repository.MessagePage) ([]model.Message, error) {
+		return mu.MessageRepo.ListConversation(userID, peerID, p)
+	})
+	if err != nil {
+		log.Printf("[MessageUseCase] Failed to load conversation %s/%s: %v\n", userID, peerID, err)
+	}
+	return messages, hasMore, err
+}
+
+// fetchPage clamps the page limit and fetches one extra row to learn whether another page exists.
+func fetchPage(page repository.MessagePage, fetch func(repository.MessagePage) ([]model.Message, error)) ([]model.Message, bool, error) {
+	if page.Limit <= 0 {
+		page.Limit = defaultHistoryLimit
+	}
+	if page.Limit > maxHistoryLimit {
+		page.Limit = maxHistoryLimit
+	}
+	limit := page.Limit
+
+	page.Limit++
+	messages, err := fetch(page)
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range messages {
+		messages[i].Redact()
+	}
+	if len(messages) <= limit {
+		return messages, false, nil
+	}
+	if page.After > 0 {
+		return messages[:limit], true, nil
+	}
+	return messages[len(messages)-limit:], true, nil
+}
+
+// GetReplay returns the messages a joining client missed: those after sinceID, or the newest lastN.
+// If the client is more than one page behind, only the newest page is returned and truncated is true.
+func (mu *MessageUseCase) GetReplay(ctx context.Context, roomID string, sinceID int64, lastN int) ([]model.Message, bool, error) {
+	if sinceID <= 0 {
+		messages, _, err := mu.GetRoomHistory(ctx, roomID, repository.MessagePage{Limit: lastN})
+		return messages, false, err
+	}
+
+	messages, hasMore, err := mu.GetRoomHistory(ctx, roomID, repository.MessagePage{After: sinceID, Limit: maxHistoryLimit})
+	if err != nil || !hasMore {
+		return messages, false, err
+	}
+	messages, _, err = mu.GetRoomHistory(ctx, roomID, repository.MessagePage{Limit: maxHistoryLimit})
+	return messages, true, err
+}
+
+// LatestMessageID returns the ID of the newest top-level message of a room, or 0 if it has none.
+func (mu *MessageUseCase) LatestMessageID(ctx context.Context, roomID string) (int64, error) {
+	messages, _, err := mu.GetRoomHistory(ctx, roomID, repository.MessagePage{Limit: 1})
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+	return messages[len(messages)-1].ID, nil
+}
+
+// maxClientMsgIDLength matches the client_msg_id column.
+const maxClientMsgIDLength = 64
+
+// maxEmojiLength bounds a reaction emoji in bytes, which keeps it within the emoji column
+// even for multi-codepoint sequences.
+const maxEmojiLength = 64
+
+// maxUserIDLength bounds user IDs taken from clients, matching the width of the ID columns.
+const maxUserIDLength = 255
+
+// ProcessMessage saves an incoming room message and broadcasts it, returning the stored message.
+// It fails if the sender is not allowed to post in the room or the message cannot be stored.
+// A message resent with an already stored ClientMsgID is not stored or broadcast again; the
+// original is returned with duplicate set.
+func (mu *MessageUseCase) ProcessMessage(ctx context.Context, msg model.Message) (*model.Message, bool, error) {
+	if err := mu.Access.AuthorizePost(ctx, msg.RoomID, msg.SenderID); err != nil {
+		log.Printf("[MessageUseCase] %s may not post to room %s: %v\n", msg.SenderID, msg.RoomID, err)
+		return nil, false, err
+	}
+
+	if msg.ParentID != 0 {
+		return mu.processReply(ctx, msg)
 	}
 
-	// Broadcast the message using MessageService.
-	// Use msg.RoomID instead of msg.Room.
-	if err := mu.MessageService.BroadcastMessage(ctx, msg.RoomID, msg.Content); err != nil {
+	stored, duplicate, err := mu.store(&msg)
+	if err != nil || duplicate {
+		return stored, duplicate, err
+	}
+
+	// Broadcast the message using MessageService. The message is stored, so a failed broadcast
+	// is not reported to the sender; other clients will see it in history.
+	if err := mu.MessageService.BroadcastMessage(ctx, stored); err != nil {
 		log.Printf("[MessageUseCase] Failed to broadcast message: %s: %v\n", msg.SenderID, err)
 	} else {
 		log.Printf("[MessageUseCase] Message broadcasted successfully: %s.", msg.SenderID)
 	}
+	return stored, false, nil
+}
+
+// processReply saves a reply to a message in the same room and broadcasts it to the thread.
+func (mu *MessageUseCase) processReply(ctx context.Context, msg model.Message) (*model.Message, bool, error) {
+	parent, err := mu.GetThreadParent(ctx, msg.SenderID, msg.ParentID)
+	if err != nil {
+		return nil, false, err
+	}
+	if parent.RoomID != msg.RoomID {
+		return nil, false, newError(model.ErrCodeInvalid, "message %d is not in room %s", parent.ID, msg.RoomID)
+	}
+	if parent.DeletedAt != nil {
+		return nil, false, errMessageNotFound(parent.ID)
+	}
+
+	stored, duplicate, err := mu.store(&msg)
+	if err != nil || duplicate {
+		return stored, duplicate, err
+	}
+
+	// Reload the parent for its updated reply count; the reply is stored either way.
+	if updated, err := mu.MessageRepo.GetMessage(parent.ID); err == nil {
+		parent = updated
+	} else {
+		log.Printf("[MessageUseCase] Failed to reload thread parent %d: %v\n", parent.ID, err)
+	}
+	if err := mu.MessageService.BroadcastReply(ctx, stored, parent); err != nil {
+		log.Printf("[MessageUseCase] Failed to broadcast reply to thread %d: %v\n", parent.ID, err)
+	}
+	return stored, false, nil
+}
+
+// GetThreadParent returns the message that starts a thread if the user can see it.
+// Only top-level room messages have threads.
+func (mu *MessageUseCase) GetThreadParent(ctx context.Context, userID string, parentID int64) (*model.Message, error) {
+	parent, err := mu.visibleMessage(ctx, userID, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.RoomID == "" || parent.ParentID != 0 {
+		return nil, newError(model.ErrCodeInvalid, "message %d cannot have replies", parentID)
+	}
+	return parent, nil
+}
+
+// GetReplies returns a page of the replies to a message the user can see, paginated like GetRoomHistory.
+func (mu *MessageUseCase) GetReplies(ctx context.Context, userID string, parentID int64, page repository.MessagePage) ([]model.Message, bool, error) {
+	if _, err := mu.GetThreadParent(ctx, userID, parentID); err != nil {
+		return nil, false, err
+	}
+	messages, hasMore, err := fetchPage(page, func(p repository.MessagePage) ([]model.Message, error) {
+		return mu.MessageRepo.ListReplies(parentID, p)
+	})
+	if err != nil {
+		log.Printf("[MessageUseCase] Failed to load replies to message %d: %v\n", parentID, err)
+		return nil, false, errInternal
+	}
+	return messages, hasMore, nil
+}
+
+// ProcessDirectMessage saves a direct message and delivers it to all of the recipient's connections.
+// Duplicates are handled as in ProcessMessage.
+func (mu *MessageUseCase) ProcessDirectMessage(ctx context.Context, msg model.Message) (*model.Message, bool, error) {
+	if msg.RecipientID == "" {
+		return nil, false, newError(model.ErrCodeInvalid, "recipient_id is required")
+	}
+	if len(msg.RecipientID) > maxUserIDLength {
+		return nil, false, newError(model.ErrCodeInvalid, "recipient_id is longer than %d characters", maxUserIDLength)
+	}
+	if msg.RecipientID == msg.SenderID {
+		return nil, false, newError(model.ErrCodeInvalid, "cannot send a direct message to yourself")
+	}
+	if msg.ParentID != 0 {
+		return nil, false, newError(model.ErrCodeInvalid, "direct messages cannot be replies")
+	}
+	msg.RoomID = ""
+
+	stored, duplicate, err := mu.store(&msg)
+	if err != nil || duplicate {
+		return stored, duplicate, err
+	}
+
+	// An offline recipient, or one the publish failed to reach, gets the message on reconnect.
+	offline := mu.Inbox.Offline(ctx, stored.RecipientID)
+	err = mu.MessageService.SendDirectMessage(ctx, stored)
+	if err != nil {
+		log.Printf("[MessageUseCase] Failed to deliver direct message: %s -> %s: %v\n", msg.SenderID, msg.RecipientID, err)
+	} else {
+		log.Printf("[MessageUseCase] Direct message delivered: %s -> %s.", msg.SenderID, msg.RecipientID)
+	}
+	if offline || err != nil {
+		mu.Inbox.Enqueue(ctx, stored.RecipientID, model.NewMessageEvent(stored))
+	}
+	return stored, false, nil
+}
+
+// EditMessage replaces the content of a message. Only the author may edit, and for room messages
+// only while still allowed to post in the room.
+func (mu *MessageUseCase) EditMessage(ctx context.Context, userID string, id int64, content string) (*model.Message, error) {
+	if content == "" {
+		return nil, newError(model.ErrCodeInvalid, "content is required")
+	}
+	msg, err := mu.visibleMessage(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != userID {
+		return nil, newError(model.ErrCodeForbidden, "only the author may edit message %d", id)
+	}
+	if msg.RoomID != "" {
+		if err := mu.Access.AuthorizePost(ctx, msg.RoomID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	if err := mu.MessageRepo.EditMessage(id, userID, content, now); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, errMessageNotFound(id)
+		}
+		log.Printf("[MessageUseCase] Failed to edit message %d: %v\n", id, err)
+		return nil, errInternal
+	}
+	msg.Content = content
+	msg.EditedAt = &now
+
+	event := model.NewMessageEvent(msg)
+	event.Type = model.EventMessageUpdated
+	_ = event.SetPayload(model.ChangePayload{ChangedBy: userID, ChangedAt: now, RecipientID: msg.RecipientID})
+	if err := mu.MessageService.PublishChange(ctx, msg, event); err != nil {
+		log.Printf("[MessageUseCase] Failed to publish edit of message %d: %v\n", id, err)
+	}
+	return msg, nil
+}
+
+// DeleteMessage soft-deletes a message. The author may delete their messages; room owners and
+// admins may delete any message in their room.
+func (mu *MessageUseCase) DeleteMessage(ctx context.Context, userID string, id int64) (*model.Message, error) {
+	msg, err := mu.visibleMessage(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != userID {
+		if msg.RoomID == "" {
+			return nil, newError(model.ErrCodeForbidden, "only the author may delete message %d", id)
+		}
+		member, err := mu.Access.member(msg.RoomID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !model.CanManage(member.Role) {
+			return nil, newError(model.ErrCodeForbidden, "only the author or a moderator may delete message %d", id)
+		}
+	}
+
+	now := time.Now().UTC()
+	if err := mu.MessageRepo.DeleteMessage(id, now); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, errMessageNotFound(id)
+		}
+		log.Printf("[MessageUseCase] Failed to delete message %d: %v\n", id, err)
+		return nil, errInternal
+	}
+	msg.DeletedAt = &now
+	msg.Redact()
+
+	event := model.NewMessageEvent(msg)
+	event.Type = model.EventMessageDeleted
+	_ = event.SetPayload(model.ChangePayload{ChangedBy: userID, ChangedAt: now, RecipientID: msg.RecipientID})
+	if err := mu.MessageService.PublishChange(ctx, msg, event); err != nil {
+		log.Printf("[MessageUseCase] Failed to publish deletion of message %d: %v\n", id, err)
+	}
+	return msg, nil
+}
+
+// GetEdits returns the edit history of a message the user can see, oldest first.
+// The history of a deleted message is not served.
+func (mu *MessageUseCase) GetEdits(ctx context.Context, userID string, id int64) ([]model.MessageEdit, error) {
+	msg, err := mu.visibleMessage(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if msg.DeletedAt != nil {
+		return nil, errMessageNotFound(id)
+	}
+	edits, err := mu.MessageRepo.ListEdits(id)
+	if err != nil {
+		log.Printf("[MessageUseCase] Failed to load edits of message %d: %v\n", id, err)
+		return nil, errInternal
+	}
+	return edits, nil
+}
+
+// React adds the user's emoji reaction to a message they can see and announces the new count.
+// Reacting again with the same emoji has no effect.
+func (mu *MessageUseCase) React(ctx context.Context, userID string, id int64, emoji string) error {
+	msg, err := mu.reactable(ctx, userID, id, emoji)
+	if err != nil {
+		return err
+	}
+	err = mu.MessageRepo.AddReaction(&model.MessageReaction{MessageID: id, UserID: userID, Emoji: emoji})
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("[MessageUseCase] Failed to add reaction to message %d: %v\n", id, err)
+		return errInternal
+	}
+	mu.publishReaction(ctx, msg, userID, emoji, 1)
+	return nil
+}
+
+// Unreact removes the user's emoji reaction from a message and announces the new count.
+// Removing a reaction that does not exist has no effect.
+func (mu *MessageUseCase) Unreact(ctx context.Context, userID string, id int64, emoji string) error {
+	msg, err := mu.reactable(ctx, userID, id, emoji)
+	if err != nil {
+		return err
+	}
+	err = mu.MessageRepo.RemoveReaction(id, userID, emoji)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("[MessageUseCase] Failed to remove reaction from message %d: %v\n", id, err)
+		return errInternal
+	}
+	mu.publishReaction(ctx, msg, userID, emoji, -1)
+	return nil
+}
+
+// reactable loads a message the user may react to: a message they can see that is not deleted,
+// in a room they may post in.
+func (mu *MessageUseCase) reactable(ctx context.Context, userID string, id int64, emoji string) (*model.Message, error) {
+	if emoji == "" || len(emoji) > maxEmojiLength {
+		return nil, newError(model.ErrCodeInvalid, "emoji must be 1 to %d bytes", maxEmojiLength)
+	}
+	msg, err := mu.visibleMessage(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if msg.DeletedAt != nil {
+		return nil, errMessageNotFound(id)
+	}
+	if msg.RoomID != "" {
+		if err := mu.Access.AuthorizePost(ctx, msg.RoomID, userID); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// publishReaction announces a reaction change with the emoji's current count.
+func (mu *MessageUseCase) publishReaction(ctx context.Context, msg *model.Message, userID, emoji string, delta int) {
+	count, err := mu.MessageRepo.CountReactions(msg.ID, emoji)
+	if err != nil {
+		log.Printf("[MessageUseCase] Failed to count reactions on message %d: %v\n", msg.ID, err)
+		return
+	}
+	event := model.NewEvent(model.EventReaction, msg.RoomID, userID)
+	event.ID = msg.ID
+	_ = event.SetPayload(model.ReactionPayload{
+		UserID:      userID,
+		Emoji:       emoji,
+		Delta:       delta,
+		Count:       count,
+		RecipientID: msg.RecipientID,
+	})
+	if err := mu.MessageService.PublishChange(ctx, msg, event); err != nil {
+		log.Printf("[MessageUseCase] Failed to publish reaction on message %d: %v\n", msg.ID, err)
+	}
+}
+
+// visibleMessage loads a message that the user may read: one in a room they can read, or a
+// direct message they sent or received. Others are reported as not found.
+func (mu *MessageUseCase) visibleMessage(ctx context.Context, userID string, id int64) (*model.Message, error) {
+	msg, err := mu.MessageRepo.GetMessage(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errMessageNotFound(id)
+	}
+	if err != nil {
+		log.Printf("[MessageUseCase] Failed to load message %d: %v\n", id, err)
+		return nil, errInternal
+	}
+
+	if msg.RoomID == "" {
+		if msg.SenderID != userID && msg.RecipientID != userID {
+			return nil, errMessageNotFound(id)
+		}
+		return msg, nil
+	}
+	if err := mu.Access.AuthorizeRead(ctx, msg.RoomID, userID); err != nil {
+		if AsError(err).Code == model.ErrCodeInternal {
+			return nil, err
+		}
+		return nil, errMessageNotFound(id)
+	}
+	return msg, nil
+}
+
+func errMessageNotFound(id int64) *Error {
+	return newError(model.ErrCodeNotFound, "message %d not found", id)
+}
+
+// store saves a message, resolving a duplicate ClientMsgID to the message stored first.
+func (mu *MessageUseCase) store(msg *model.Message) (*model.Message, bool, error) {
+	if msg.Content == "" {
+		return nil, false, newError(model.ErrCodeInvalid, "content is required")
+	}
+	if len(msg.ClientMsgID) > maxClientMsgIDLength {
+		return nil, false, newError(model.ErrCodeInvalid, "client_msg_id is longer than %d characters", maxClientMsgIDLength)
+	}
+
+	// The ID, timestamps and edit state are assigned by the server, never taken from the client.
+	msg.ID = 0
+	msg.CreatedAt = time.Time{}
+	msg.EditedAt, msg.DeletedAt = nil, nil
+	msg.ReplyCount, msg.LastReplyAt = 0, nil
+
+	err := mu.MessageRepo.CreateMessage(msg)
+	if errors.Is(err, repository.ErrDuplicate) {
+		original, err := mu.MessageRepo.GetMessageByClientMsgID(msg.SenderID, msg.ClientMsgID)
+		if err != nil {
+			log.Printf("[MessageUseCase] Failed to load duplicate message %s/%s: %v\n", msg.SenderID, msg.ClientMsgID, err)
+			return nil, false, errInternal
+		}
+		log.Printf("[MessageUseCase] Duplicate message %s/%s resolved to %d.", msg.SenderID, msg.ClientMsgID, original.ID)
+		return original, true, nil
+	}
+	if err != nil {
+		log.Printf("[MessageUseCase] Failed to save message: %v\n", err)
+		return nil, false, errInternal
+	}
+	log.Printf("[MessageUseCase] Message saved successfully.")
+	return msg, false, nil
 }
@@ -0,0 +1,330 @@
+// usecase/message_usecase_test.go
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"chat-websocket/model"
+	"chat-websocket/repository"
+)
+
+// checkCode fails the test unless err carries the wanted error code, or is nil if none is wanted.
+func checkCode(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || AsError(err).Code != want {
+		t.Fatalf("error = %v, want code %s", err, want)
+	}
+}
+
+func TestMessageUseCaseProcessMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         model.Message
+		wantCode    string
+		wantHistory int  // Messages in the lobby's history afterwards.
+		wantLive    bool // Whether a lobby member receives the message.
+	}{
+		{
+			name:        "stored and broadcast to the room",
+			msg:         model.Message{RoomID: "lobby", SenderID: "alice", Content: "hello"},
+			wantHistory: 1,
+			wantLive:    true,
+		},
+		{
+			name: "other rooms are unaffected",
+			msg:  model.Message{RoomID: "garden", SenderID: "alice", Content: "hello"},
+		},
+		{
+			name:     "non-member",
+			msg:      model.Message{RoomID: "lobby", SenderID: "mallory", Content: "hello"},
+			wantCode: model.ErrCodeForbidden,
+		},
+		{
+			name:     "empty content",
+			msg:      model.Message{RoomID: "lobby", SenderID: "alice"},
+			wantCode: model.ErrCodeInvalid,
+		},
+		{
+			name:     "client_msg_id too long",
+			msg:      model.Message{RoomID: "lobby", SenderID: "alice", Content: "hello", ClientMsgID: strings.Repeat("x", maxClientMsgIDLength+1)},
+			wantCode: model.ErrCodeInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			env := newTestEnv(t)
+			member := env.connect(t, "bob-1", "bob")
+			if err := env.rooms.JoinRoom(ctx, member.Client, "lobby"); err != nil {
+				t.Fatalf("join: %v", err)
+			}
+			for _, room := range []string{"lobby", "garden"} {
+				if err := env.access.AuthorizeJoin(ctx, room, "alice"); err != nil {
+					t.Fatalf("join %s: %v", room, err)
+				}
+			}
+			member.received()
+
+			stored, _, err := env.messages.ProcessMessage(ctx, tt.msg)
+			checkCode(t, err, tt.wantCode)
+			if err == nil && (stored.ID == 0 || stored.Content != tt.msg.Content) {
+				t.Errorf("stored message = %+v", stored)
+			}
+
+			history, _, err := env.messages.GetRoomHistory(ctx, "lobby", repository.MessagePage{})
+			if err != nil {
+				t.Fatalf("history: %v", err)
+			}
+			if len(history) != tt.wantHistory {
+				t.Errorf("history has %d messages, want %d", len(history), tt.wantHistory)
+			}
+			if got := len(member.received(model.EventMessage)) > 0; got != tt.wantLive {
+				t.Errorf("received message = %v, want %v", got, tt.wantLive)
+			}
+		})
+	}
+}
+
+func TestMessageUseCaseDeduplicate(t *testing.T) {
+	tests := []struct {
+		name        string
+		first       model.Message
+		resend      model.Message
+		duplicate   bool
+		wantHistory int // Messages stored, each broadcast once.
+	}{
+		{
+			name:        "resent client_msg_id",
+			first:       model.Message{SenderID: "alice", Content: "hello", ClientMsgID: "m1"},
+			resend:      model.Message{SenderID: "alice", Content: "hello again", ClientMsgID: "m1"},
+			duplicate:   true,
+			wantHistory: 1,
+		},
+		{
+			name:        "client_msg_id reused by another sender",
+			first:       model.Message{SenderID: "alice", Content: "hello", ClientMsgID: "m1"},
+			resend:      model.Message{SenderID: "bob", Content: "hello", ClientMsgID: "m1"},
+			wantHistory: 2,
+		},
+		{
+			name:        "no client_msg_id",
+			first:       model.Message{SenderID: "alice", Content: "hello"},
+			resend:      model.Message{SenderID: "alice", Content: "hello"},
+			wantHistory: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			env := newTestEnv(t)
+			member := env.connect(t, "carol-1", "carol")
+			if err := env.rooms.JoinRoom(ctx, member.Client, "lobby"); err != nil {
+				t.Fatalf("join: %v", err)
+			}
+			for _, user := range []string{"alice", "bob"} {
+				if err := env.access.AuthorizeJoin(ctx, "lobby", user); err != nil {
+					t.Fatalf("join %s: %v", user, err)
+				}
+			}
+			member.received()
+
+			tt.first.RoomID, tt.resend.RoomID = "lobby", "lobby"
+			first, _, err := env.messages.ProcessMessage(ctx, tt.first)
+			if err != nil {
+				t.Fatalf("first: %v", err)
+			}
+			again, duplicate, err := env.messages.ProcessMessage(ctx, tt.resend)
+			if err != nil {
+				t.Fatalf("resend: %v", err)
+			}
+			if duplicate != tt.duplicate || (again.ID == first.ID) != tt.duplicate {
+				t.Errorf("resend = %d (duplicate %v), first = %d, want duplicate %v", again.ID, duplicate, first.ID, tt.duplicate)
+			}
+			if tt.duplicate && again.Content != first.Content {
+				t.Errorf("duplicate content = %q, want the original %q", again.Content, first.Content)
+			}
+
+			history, _, err := env.messages.GetRoomHistory(ctx, "lobby", repository.MessagePage{})
+			if err != nil {
+				t.Fatalf("history: %v", err)
+			}
+			if len(history) != tt.wantHistory {
+				t.Errorf("history has %d messages, want %d", len(history), tt.wantHistory)
+			}
+			if got := len(member.received(model.EventMessage)); got != tt.wantHistory {
+				t.Errorf("member received %d messages, want %d", got, tt.wantHistory)
+			}
+		})
+	}
+}
+
+func TestMessageUseCaseGetRoomHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     repository.MessagePage
+		wantIDs  []int64
+		wantMore bool
+	}{
+		{name: "newest page", page: repository.MessagePage{Limit: 2}, wantIDs: []int64{4, 5}, wantMore: true},
+		{name: "before a cursor", page: repository.MessagePage{Before: 3, Limit: 5}, wantIDs: []int64{1, 2}},
+		{name: "after a cursor", page: repository.MessagePage{After: 1, Limit: 2}, wantIDs: []int64{2, 3}, wantMore: true},
+		{name: "after the newest", page: repository.MessagePage{After: 5}, wantIDs: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			env := newTestEnv(t)
+			if err := env.access.AuthorizeJoin(ctx, "lobby", "alice"); err != nil {
+				t.Fatalf("join: %v", err)
+			}
+			for i := 0; i < 5; i++ {
+				if _, _, err := env.messages.ProcessMessage(ctx, model.Message{RoomID: "lobby", SenderID: "alice", Content: "hello"}); err != nil {
+					t.Fatalf("message %d: %v", i, err)
+				}
+			}
+
+			messages, hasMore, err := env.messages.GetRoomHistory(ctx, "lobby", tt.page)
+			if err != nil {
+				t.Fatalf("history: %v", err)
+			}
+			var ids []int64
+			for _, msg := range messages {
+				ids = append(ids, msg.ID)
+			}
+			if len(ids) != len(tt.wantIDs) || hasMore != tt.wantMore {
+				t.Fatalf("history = %v (more %v), want %v (more %v)", ids, hasMore, tt.wantIDs, tt.wantMore)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Fatalf("history = %v, want %v", ids, tt.wantIDs)
+				}
+			}
+		})
+	}
+}
+
+func TestMessageUseCaseProcessDirectMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       model.Message
+		wantCode  string
+		wantLive  int // Connections of bob that receive the message.
+		wantSaved int // Messages in the conversation afterwards.
+		wantInbox int // Entries queued for the offline recipient.
+	}{
+		{
+			name:      "delivered to every connection of the recipient",
+			msg:       model.Message{RecipientID: "bob", Content: "hi"},
+			wantLive:  2,
+			wantSaved: 1,
+		},
+		{
+			name:      "queued for an offline recipient",
+			msg:       model.Message{RecipientID: "dave", Content: "hi"},
+			wantSaved: 1,
+			wantInbox: 1,
+		},
+		{
+			name:     "missing recipient",
+			msg:      model.Message{Content: "hi"},
+			wantCode: model.ErrCodeInvalid,
+		},
+		{
+			name:     "client_msg_id too long",
+			msg:      model.Message{RecipientID: "bob", Content: "hi", ClientMsgID: strings.Repeat("x", maxClientMsgIDLength+1)},
+			wantCode: model.ErrCodeInvalid,
+		},
+		{
+			name:     "recipient too long",
+			msg:      model.Message{RecipientID: strings.Repeat("b", maxUserIDLength+1), Content: "hi"},
+			wantCode: model.ErrCodeInvalid,
+		},
+		{
+			name:     "to self",
+			msg:      model.Message{RecipientID: "alice", Content: "hi"},
+			wantCode: model.ErrCodeInvalid,
+		},
+		{
+			name:     "empty content",
+			msg:      model.Message{RecipientID: "bob"},
+			wantCode: model.ErrCodeInvalid,
+		},
+		{
+			name:     "reply",
+			msg:      model.Message{RecipientID: "bob", Content: "hi", ParentID: 1},
+			wantCode: model.ErrCodeInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			env := newTestEnv(t)
+			phone := env.connect(t, "bob-1", "bob")
+			laptop := env.connect(t, "bob-2", "bob")
+
+			msg := tt.msg
+			msg.SenderID = "alice"
+			_, _, err := env.messages.ProcessDirectMessage(ctx, msg)
+			checkCode(t, err, tt.wantCode)
+
+			live := 0
+			for _, client := range []*testClient{phone, laptop} {
+				live += len(client.received(model.EventDirect))
+			}
+			if live != tt.wantLive {
+				t.Errorf("delivered to %d connections, want %d", live, tt.wantLive)
+			}
+			if msg.RecipientID == "" {
+				return
+			}
+			saved, _, err := env.messages.GetConversationHistory(ctx, "alice", msg.RecipientID, repository.MessagePage{})
+			if err != nil {
+				t.Fatalf("conversation: %v", err)
+			}
+			if len(saved) != tt.wantSaved {
+				t.Errorf("conversation has %d messages, want %d", len(saved), tt.wantSaved)
+			}
+			pending, _, err := env.inbox.Pending(ctx, msg.RecipientID, 0, 100)
+			if err != nil {
+				t.Fatalf("pending: %v", err)
+			}
+			if len(pending) != tt.wantInbox {
+				t.Errorf("inbox has %d entries, want %d", len(pending), tt.wantInbox)
+			}
+		})
+	}
+}
+
+func TestMessageUseCaseDirectMessageDuplicate(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	recipient := env.connect(t, "bob-1", "bob")
+
+	msg := model.Message{SenderID: "alice", RecipientID: "bob", Content: "hi", ClientMsgID: "dm1"}
+	first, _, err := env.messages.ProcessDirectMessage(ctx, msg)
+	if err != nil {
+		t.Fatalf("first: %v", err)
+	}
+	again, duplicate, err := env.messages.ProcessDirectMessage(ctx, msg)
+	if err != nil {
+		t.Fatalf("resend: %v", err)
+	}
+	if !duplicate || again.ID != first.ID {
+		t.Errorf("resend = %d (duplicate %v), want %d (duplicate true)", again.ID, duplicate, first.ID)
+	}
+	if got := len(recipient.received(model.EventDirect)); got != 1 {
+		t.Errorf("recipient received %d messages, want 1", got)
+	}
+}
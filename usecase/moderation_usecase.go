@@ -0,0 +1,214 @@
+// usecase/moderation_usecase.go
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"chat-websocket/model"
+	"chat-websocket/redis"
+	"chat-websocket/repository"
+)
+
+// maxReasonLength bounds the reason a moderator may give.
+const maxReasonLength = 500
+
+// ModerationUseCase lets room owners and admins kick, ban and mute users. Every action is
+// published as a moderation event on the room's topic; each node removes kicked and banned
+// users from its local room (see RoomUseCase), so actions take effect cluster-wide.
+type ModerationUseCase struct {
+	access     *RoomAccessUseCase
+	rooms      repository.RoomRepository
+	sanctions  repository.SanctionRepository
+	pubSubRepo redis.PubSubRepository
+}
+
+// NewModerationUseCase creates a new ModerationUseCase instance.
+func NewModerationUseCase(access *RoomAccessUseCase, rooms repository.RoomRepository, sanctions repository.SanctionRepository, pubSubRepo redis.PubSubRepository) *ModerationUseCase {
+	return &ModerationUseCase{
+		access:     access,
+		rooms:      rooms,
+		sanctions:  sanctions,
+		pubSubRepo: pubSubRepo,
+	}
+}
+
+// ModerationRequest describes a moderation action by ModeratorID against UserID in RoomID.
+type ModerationRequest struct {
+	RoomID      string
+	ModeratorID string
+	UserID      string
+	Duration    time.Duration // How long a ban or mute lasts; zero means indefinitely.
+	Reason      string
+}
+
+// Kick removes the user's connections from the room. The user may rejoin.
+func (mu *ModerationUseCase) Kick(ctx context.Context, req ModerationRequest) error {
+	if err := mu.authorize(req); err != nil {
+		return err
+	}
+	mu.publish(ctx, req, model.ModerationKick, nil)
+	return nil
+}
+
+// Ban removes the user from the room and its membership, and keeps them out until the ban expires.
+func (mu *ModerationUseCase) Ban(ctx context.Context, req ModerationRequest) error {
+	if err := mu.authorize(req); err != nil {
+		return err
+	}
+	sanction, err := mu.impose(req, model.SanctionBan)
+	if err != nil {
+		return err
+	}
+	if err := mu.rooms.RemoveMember(req.RoomID, req.UserID); err != nil {
+		return mu.access.internal(req.RoomID, err)
+	}
+	mu.publish(ctx, req, model.ModerationBan, sanction.ExpiresAt)
+	return nil
+}
+
+// Mute stops the user from posting in the room until the mute expires.
+func (mu *ModerationUseCase) Mute(ctx context.Context, req ModerationRequest) error {
+	if err := mu.authorize(req); err != nil {
+		return err
+	}
+	sanction, err := mu.impose(req, model.SanctionMute)
+	if err != nil {
+		return err
+	}
+	mu.publish(ctx, req, model.ModerationMute, sanction.ExpiresAt)
+	return nil
+}
+
+// Unban lifts the user's ban. The user is notified on their user topic as they are not in the room.
+func (mu *ModerationUseCase) Unban(ctx context.Context, req ModerationRequest) error {
+	if err := mu.lift(req, model.SanctionBan); err != nil {
+		return err
+	}
+	event := mu.publish(ctx, req, model.ModerationUnban, nil)
+	if err := mu.pubSubRepo.Publish(ctx, redis.UserTopic(req.UserID), event); err != nil {
+		log.Printf("[ModerationUseCase] Failed to notify %s of unban from room %s: %v\n", req.UserID, req.RoomID, err)
+	}
+	return nil
+}
+
+// Unmute lifts the user's mute.
+func (mu *ModerationUseCase) Unmute(ctx context.Context, req ModerationRequest) error {
+	if err := mu.lift(req, model.SanctionMute); err != nil {
+		return err
+	}
+	mu.publish(ctx, req, model.ModerationUnmute, nil)
+	return nil
+}
+
+// ListSanctions returns the bans and mutes in force in a room. Only owners and admins may list them.
+func (mu *ModerationUseCase) ListSanctions(ctx context.Context, roomID, userID string) ([]model.RoomSanction, error) {
+	if _, err := mu.moderator(roomID, userID); err != nil {
+		return nil, err
+	}
+	sanctions, err := mu.sanctions.ListActiveSanctions(roomID, time.Now())
+	if err != nil {
+		return nil, mu.access.internal(roomID, err)
+	}
+	return sanctions, nil
+}
+
+// authorize checks that the moderator may act on the user: owners may act on anyone but
+// themselves, admins only on plain members and non-members.
+func (mu *ModerationUseCase) authorize(req ModerationRequest) error {
+	if req.UserID == "" || req.UserID == req.ModeratorID {
+		return newError(model.ErrCodeInvalid, "moderation needs another user_id")
+	}
+	if len(req.Reason) > maxReasonLength {
+		return newError(model.ErrCodeInvalid, "reason is longer than %d characters", maxReasonLength)
+	}
+	if req.Duration < 0 {
+		return newError(model.ErrCodeInvalid, "duration must not be negative")
+	}
+
+	moderator, err := mu.moderator(req.RoomID, req.ModeratorID)
+	if err != nil {
+		return err
+	}
+	target, err := mu.rooms.GetMember(req.RoomID, req.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return mu.access.internal(req.RoomID, err)
+	}
+	if target.Role == model.RoleOwner || (target.Role == model.RoleAdmin && moderator.Role != model.RoleOwner) {
+		return newError(model.ErrCodeForbidden, "you may not moderate %s %s", target.Role, req.UserID)
+	}
+	return nil
+}
+
+// moderator loads the user's membership, requiring the owner or admin role.
+func (mu *ModerationUseCase) moderator(roomID, userID string) (*model.RoomMembership, error) {
+	if _, err := mu.access.room(roomID); err != nil {
+		return nil, err
+	}
+	member, err := mu.access.member(roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !model.CanManage(member.Role) {
+		return nil, newError(model.ErrCodeForbidden, "only owners and admins may moderate room %s", roomID)
+	}
+	return member, nil
+}
+
+// impose stores a sanction of the given kind for the request.
+func (mu *ModerationUseCase) impose(req ModerationRequest, kind string) (*model.RoomSanction, error) {
+	sanction := &model.RoomSanction{
+		RoomID:      req.RoomID,
+		UserID:      req.UserID,
+		Kind:        kind,
+		ModeratorID: req.ModeratorID,
+		Reason:      req.Reason,
+	}
+	if req.Duration > 0 {
+		expiresAt := time.Now().Add(req.Duration).UTC()
+		sanction.ExpiresAt = &expiresAt
+	}
+	if err := mu.sanctions.SaveSanction(sanction); err != nil {
+		return nil, mu.access.internal(req.RoomID, err)
+	}
+	log.Printf("[ModerationUseCase] %s imposed %s on %s in room %s (duration %s)", req.ModeratorID, kind, req.UserID, req.RoomID, req.Duration)
+	return sanction, nil
+}
+
+// lift removes a sanction of the given kind after checking the moderator's role.
+func (mu *ModerationUseCase) lift(req ModerationRequest, kind string) error {
+	if req.UserID == "" {
+		return newError(model.ErrCodeInvalid, "user_id is required")
+	}
+	if _, err := mu.moderator(req.RoomID, req.ModeratorID); err != nil {
+		return err
+	}
+	if err := mu.sanctions.DeleteSanction(req.RoomID, req.UserID, kind, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return newError(model.ErrCodeNotFound, "%s has no %s in room %s", req.UserID, kind, req.RoomID)
+		}
+		return mu.access.internal(req.RoomID, err)
+	}
+	log.Printf("[ModerationUseCase] %s lifted %s on %s in room %s", req.ModeratorID, kind, req.UserID, req.RoomID)
+	return nil
+}
+
+// publish broadcasts a moderation event to the room and returns it.
+func (mu *ModerationUseCase) publish(ctx context.Context, req ModerationRequest, action string, expiresAt *time.Time) *model.Event {
+	event := model.NewEvent(model.EventModeration, req.RoomID, req.ModeratorID)
+	_ = event.SetPayload(model.ModerationPayload{
+		Action:    action,
+		UserID:    req.UserID,
+		Reason:    req.Reason,
+		ExpiresAt: expiresAt,
+	})
+	if err := mu.pubSubRepo.Publish(ctx, redis.RoomTopic(req.RoomID), event); err != nil {
+		log.Printf("[ModerationUseCase] Failed to publish %s of %s in room %s: %v\n", action, req.UserID, req.RoomID, err)
+	}
+	return event
+}
@@ -0,0 +1,298 @@
+// usecase/presence_usecase.go
+package usecase
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"chat-websocket/model"
+	"chat-websocket/redis"
+)
+
+// PresenceUseCase publishes this node's connections to the cluster-wide presence store,
+// keeps them alive by heartbeat, and emits presence events into rooms when a user's
+// aggregate status changes.
+type PresenceUseCase struct {
+	repo       redis.PresenceRepository
+	pubSubRepo redis.PubSubRepository
+	nodeID     string
+	ttl        time.Duration
+	interval   time.Duration
+
+	mutex  sync.Mutex
+	conns  map[string]*model.Presence // Local connections keyed by client ID.
+	online map[string]bool            // Client IDs of local connections that brought their user online.
+}
+
+// NewPresenceUseCase creates a new PresenceUseCase instance. Entries expire after ttl unless
+// refreshed; the heartbeat runs every interval, which must be shorter than ttl.
+func NewPresenceUseCase(repo redis.PresenceRepository, pubSubRepo redis.PubSubRepository, nodeID string, ttl, interval time.Duration) *PresenceUseCase {
+	return &PresenceUseCase{
+		repo:       repo,
+		pubSubRepo: pubSubRepo,
+		nodeID:     nodeID,
+		ttl:        ttl,
+		interval:   interval,
+		conns:      make(map[string]*model.Presence),
+		online:     make(map[string]bool),
+	}
+}
+
+// Run refreshes local entries and reaps expired ones every interval until ctx is done.
+func (pu *PresenceUseCase) Run(ctx context.Context) {
+	ticker := time.NewTicker(pu.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			pu.heartbeat(ctx)
+			pu.reap(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Connect records a new local connection as online. If the user had no live connection in the
+// cluster, the user is announced online in each room this connection joins.
+func (pu *PresenceUseCase) Connect(ctx context.Context, client *model.Client) {
+	// Checked before recording, so that first connections racing on two nodes are both
+	// announced rather than neither.
+	before, err := pu.repo.UserConnections(ctx, client.SenderID)
+	if err != nil {
+		log.Printf("[PresenceUseCase] Failed to load presence of %s: %v", client.SenderID, err)
+	}
+	first := err == nil && len(before) == 0
+
+	p := &model.Presence{
+		UserID:      client.SenderID,
+		ConnID:      client.ID,
+		NodeID:      pu.nodeID,
+		Status:      model.StatusOnline,
+		ConnectedAt: time.Now().UTC(),
+		IP:          client.IP,
+		UserAgent:   client.UserAgent,
+	}
+	pu.mutex.Lock()
+	pu.conns[client.ID] = p
+	if first {
+		pu.online[client.ID] = true
+	}
+	snapshot := *p
+	pu.mutex.Unlock()
+
+	if err := pu.repo.Touch(ctx, snapshot, pu.ttl); err != nil {
+		log.Printf("[PresenceUseCase] Failed to record connection %s: %v", client.ID, err)
+	}
+}
+
+// Disconnect removes a local connection; if it was the user's last, the user goes offline.
+func (pu *PresenceUseCase) Disconnect(ctx context.Context, client *model.Client) {
+	pu.mutex.Lock()
+	p, ok := pu.conns[client.ID]
+	delete(pu.conns, client.ID)
+	delete(pu.online, client.ID)
+	pu.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	remaining, err := pu.repo.Remove(ctx, *p)
+	if err != nil {
+		log.Printf("[PresenceUseCase] Failed to remove connection %s: %v", client.ID, err)
+		return
+	}
+	if remaining == 0 {
+		pu.publish(ctx, p.UserID, model.StatusOffline, p.Rooms)
+	}
+}
+
+// JoinedRoom records that a local connection is present in a room.
+func (pu *PresenceUseCase) JoinedRoom(ctx context.Context, client *model.Client, roomID string) {
+	pu.mutex.Lock()
+	p, ok := pu.conns[client.ID]
+	if !ok {
+		pu.mutex.Unlock()
+		return
+	}
+	for _, room := range p.Rooms {
+		if room == roomID {
+			pu.mutex.Unlock()
+			return
+		}
+	}
+	p.Rooms = append(p.Rooms, roomID)
+	snapshot := copyPresence(p)
+	announce := pu.online[client.ID]
+	pu.mutex.Unlock()
+
+	if err := pu.repo.Touch(ctx, snapshot, pu.ttl); err != nil {
+		log.Printf("[PresenceUseCase] Failed to record room %s for %s: %v", roomID, client.ID, err)
+	}
+	if announce {
+		pu.publish(ctx, snapshot.UserID, snapshot.Status, []string{roomID})
+	}
+}
+
+// LeftRoom records that a local connection is no longer present in a room.
+func (pu *PresenceUseCase) LeftRoom(ctx context.Context, client *model.Client, roomID string) {
+	pu.mutex.Lock()
+	p, ok := pu.conns[client.ID]
+	if !ok {
+		pu.mutex.Unlock()
+		return
+	}
+	rooms := p.Rooms[:0]
+	for _, room := range p.Rooms {
+		if room != roomID {
+			rooms = append(rooms, room)
+		}
+	}
+	p.Rooms = rooms
+	snapshot := copyPresence(p)
+	pu.mutex.Unlock()
+
+	if err := pu.repo.RemoveRoom(ctx, roomID, snapshot.UserID, snapshot.ConnID); err != nil {
+		log.Printf("[PresenceUseCase] Failed to remove room %s for %s: %v", roomID, client.ID, err)
+	}
+	if err := pu.repo.Touch(ctx, snapshot, pu.ttl); err != nil {
+		log.Printf("[PresenceUseCase] Failed to refresh %s: %v", client.ID, err)
+	}
+}
+
+// SetStatus sets a local connection to online or away and announces the user's new
+// aggregate status to their rooms if it changed.
+func (pu *PresenceUseCase) SetStatus(ctx context.Context, client *model.Client, status string) {
+	if status != model.StatusOnline && status != model.StatusAway {
+		log.Printf("[PresenceUseCase] Invalid status %q from client %s", status, client.ID)
+		return
+	}
+
+	before, err := pu.repo.UserConnections(ctx, client.SenderID)
+	if err != nil {
+		log.Printf("[PresenceUseCase] Failed to load presence of %s: %v", client.SenderID, err)
+		return
+	}
+
+	pu.mutex.Lock()
+	p, ok := pu.conns[client.ID]
+	if !ok || p.Status == status {
+		pu.mutex.Unlock()
+		return
+	}
+	p.Status = status
+	snapshot := copyPresence(p)
+	pu.mutex.Unlock()
+
+	if err := pu.repo.Touch(ctx, snapshot, pu.ttl); err != nil {
+		log.Printf("[PresenceUseCase] Failed to update status of %s: %v", client.ID, err)
+		return
+	}
+
+	after, err := pu.repo.UserConnections(ctx, client.SenderID)
+	if err != nil {
+		log.Printf("[PresenceUseCase] Failed to load presence of %s: %v", client.SenderID, err)
+		return
+	}
+	if newStatus := model.AggregateStatus(after); newStatus != model.AggregateStatus(before) {
+		pu.publish(ctx, client.SenderID, newStatus, unionRooms(after))
+	}
+}
+
+// RoomMember is one user present in a room with their aggregate status.
+type RoomMember struct {
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+}
+
+// RoomMembers returns the users currently present in a room anywhere in the cluster.
+func (pu *PresenceUseCase) RoomMembers(ctx context.Context, roomID string) ([]RoomMember, error) {
+	conns, err := pu.repo.RoomMembers(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[string][]model.Presence)
+	for _, c := range conns {
+		byUser[c.UserID] = append(byUser[c.UserID], c)
+	}
+	members := make([]RoomMember, 0, len(byUser))
+	for userID, userConns := range byUser {
+		members = append(members, RoomMember{UserID: userID, Status: model.AggregateStatus(userConns)})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID < members[j].UserID })
+	return members, nil
+}
+
+// UserPresence returns a user's aggregate status and live connections.
+func (pu *PresenceUseCase) UserPresence(ctx context.Context, userID string) (string, []model.Presence, error) {
+	conns, err := pu.repo.UserConnections(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	return model.AggregateStatus(conns), conns, nil
+}
+
+// heartbeat refreshes the TTL of every local connection.
+func (pu *PresenceUseCase) heartbeat(ctx context.Context) {
+	pu.mutex.Lock()
+	snapshots := make([]model.Presence, 0, len(pu.conns))
+	for _, p := range pu.conns {
+		snapshots = append(snapshots, copyPresence(p))
+	}
+	pu.mutex.Unlock()
+
+	for _, p := range snapshots {
+		if err := pu.repo.Touch(ctx, p, pu.ttl); err != nil {
+			log.Printf("[PresenceUseCase] Heartbeat failed for %s: %v", p.ConnID, err)
+		}
+	}
+}
+
+// reap clears entries left behind by crashed nodes and announces those users as offline.
+func (pu *PresenceUseCase) reap(ctx context.Context) {
+	expired, err := pu.repo.ReapExpired(ctx, pu.nodeID, pu.interval)
+	if err != nil {
+		log.Printf("[PresenceUseCase] Failed to reap expired presence: %v", err)
+		return
+	}
+	for _, e := range expired {
+		log.Printf("[PresenceUseCase] User %s expired without disconnecting", e.UserID)
+		pu.publish(ctx, e.UserID, model.StatusOffline, e.Rooms)
+	}
+}
+
+// publish sends a presence event for userID into each of the given rooms.
+func (pu *PresenceUseCase) publish(ctx context.Context, userID, status string, rooms []string) {
+	for _, room := range rooms {
+		event := model.NewEvent(model.EventPresence, room, userID)
+		_ = event.SetPayload(model.PresencePayload{UserID: userID, Status: status})
+		if err := pu.pubSubRepo.Publish(ctx, redis.RoomTopic(room), event); err != nil {
+			log.Printf("[PresenceUseCase] Failed to publish presence to room %s: %v", room, err)
+		}
+	}
+}
+
+func copyPresence(p *model.Presence) model.Presence {
+	c := *p
+	c.Rooms = append([]string(nil), p.Rooms...)
+	return c
+}
+
+func unionRooms(conns []model.Presence) []string {
+	seen := make(map[string]bool)
+	var rooms []string
+	for _, c := range conns {
+		for _, room := range c.Rooms {
+			if !seen[room] {
+				seen[room] = true
+				rooms = append(rooms, room)
+			}
+		}
+	}
+	return rooms
+}
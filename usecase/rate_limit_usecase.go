@@ -0,0 +1,125 @@
+// usecase/rate_limit_usecase.go
+package usecase
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"chat-websocket/model"
+	"chat-websocket/pkg/metrics"
+	"chat-websocket/redis"
+)
+
+// Rate limit scopes, in the order their buckets are checked.
+var rateLimitScopes = []string{"user", "ip", "room"}
+
+// RateLimits configures inbound frame limiting.
+type RateLimits struct {
+	User redis.RateLimit // Per sender ID, across all of the user's connections.
+	IP   redis.RateLimit // Per client IP address.
+	Room redis.RateLimit // Per room, across all senders; applies to frames for a room the connection has joined.
+	// Typing is a per-connection bucket for typing starts, checked locally instead of the shared buckets.
+	Typing redis.RateLimit
+
+	// A connection throttled MaxStrikes times within StrikeWindow is disconnected; zero disables escalation.
+	MaxStrikes   int
+	StrikeWindow time.Duration
+}
+
+// RateLimitUseCase throttles inbound frames with token buckets shared by the cluster and
+// escalates repeat offenders to disconnection.
+type RateLimitUseCase struct {
+	limiter redis.RateLimiter
+	limits  RateLimits
+}
+
+// NewRateLimitUseCase creates a new RateLimitUseCase instance.
+func NewRateLimitUseCase(limiter redis.RateLimiter, limits RateLimits) *RateLimitUseCase {
+	return &RateLimitUseCase{limiter: limiter, limits: limits}
+}
+
+// Strikes counts the throttled frames of one connection within the strike window.
+// It belongs to the connection's read loop and is not safe for concurrent use.
+type Strikes struct {
+	count int
+	since time.Time
+}
+
+// Check draws a token for a frame from the user's, the IP's and (if roomID is set) the room's
+// bucket. It returns a rate_limited error if the frame must be rejected, together with whether
+// the connection has run out of strikes and must be closed. Limiter failures let the frame through.
+func (ru *RateLimitUseCase) Check(ctx context.Context, strikes *Strikes, userID, ip, roomID string) (bool, error) {
+	buckets := []redis.RateBucket{
+		{Key: "ratelimit:user:" + userID, Limit: ru.limits.User},
+		{Key: "ratelimit:ip:" + ip, Limit: ru.limits.IP},
+	}
+	if roomID != "" {
+		buckets = append(buckets, redis.RateBucket{Key: "ratelimit:room:" + roomID, Limit: ru.limits.Room})
+	}
+
+	denied, retryAfter, err := ru.limiter.Allow(ctx, buckets)
+	if err != nil {
+		log.Printf("[RateLimitUseCase] Rate limiter unavailable, allowing frame from %s: %v", userID, err)
+		return false, nil
+	}
+	if denied < 0 {
+		return false, nil
+	}
+
+	return ru.throttle(strikes, rateLimitScopes[denied], retryAfter)
+}
+
+// TypingBucket is a connection's token bucket for typing starts. Clients may send one per
+// keystroke, so they are drawn from this local bucket rather than from the shared ones.
+// It belongs to the connection's read loop and is not safe for concurrent use.
+type TypingBucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+// CheckTyping draws a token for a typing start from the connection's typing bucket, returning
+// the same results as Check.
+func (ru *RateLimitUseCase) CheckTyping(strikes *Strikes, bucket *TypingBucket) (bool, error) {
+	limit := ru.limits.Typing
+	if limit.Burst <= 0 || limit.Rate <= 0 {
+		return false, nil
+	}
+
+	now := time.Now()
+	tokens := float64(limit.Burst)
+	if !bucket.ts.IsZero() {
+		tokens = math.Min(tokens, bucket.tokens+now.Sub(bucket.ts).Seconds()*limit.Rate)
+	}
+	if tokens < 1 {
+		return ru.throttle(strikes, "typing", time.Duration((1-tokens)/limit.Rate*float64(time.Second)))
+	}
+	bucket.tokens, bucket.ts = tokens-1, now
+	return false, nil
+}
+
+// throttle records a throttled frame and builds its rate_limited error.
+func (ru *RateLimitUseCase) throttle(strikes *Strikes, scope string, retryAfter time.Duration) (bool, error) {
+	metrics.ThrottledFrames.WithLabelValues(scope).Inc()
+	limited := &Error{
+		Code:       model.ErrCodeRateLimited,
+		Message:    "too many messages (" + scope + " limit)",
+		RetryAfter: retryAfter,
+	}
+	return strikes.add(ru.limits.MaxStrikes, ru.limits.StrikeWindow), limited
+}
+
+// add records a strike and reports whether max strikes were reached within window.
+func (s *Strikes) add(max int, window time.Duration) bool {
+	if max <= 0 {
+		return false
+	}
+	now := time.Now()
+	if now.Sub(s.since) > window {
+		s.count = 0
+		s.since = now
+	}
+	s.count++
+	return s.count >= max
+}
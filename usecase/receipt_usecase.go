@@ -0,0 +1,128 @@
+// usecase/receipt_usecase.go
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"chat-websocket/model"
+	"chat-websocket/redis"
+	"chat-websocket/repository"
+)
+
+// ReceiptUseCase records how far each user has read in each room and derives unread counts.
+// Watermarks only move forward; they are stored in the database and cached in Redis.
+type ReceiptUseCase struct {
+	repo       repository.ReceiptRepository
+	cache      redis.ReadReceiptCache
+	messages   repository.MessageRepository
+	rooms      repository.RoomRepository
+	access     *RoomAccessUseCase
+	pubSubRepo redis.PubSubRepository
+}
+
+// NewReceiptUseCase creates a new ReceiptUseCase instance.
+func NewReceiptUseCase(repo repository.ReceiptRepository, cache redis.ReadReceiptCache, messages repository.MessageRepository, rooms repository.RoomRepository, access *RoomAccessUseCase, pubSubRepo redis.PubSubRepository) *ReceiptUseCase {
+	return &ReceiptUseCase{
+		repo:       repo,
+		cache:      cache,
+		messages:   messages,
+		rooms:      rooms,
+		access:     access,
+		pubSubRepo: pubSubRepo,
+	}
+}
+
+// MarkRead advances the user's watermark in a room to messageID and, if it moved, announces a
+// receipt to the room. Reporting an older message than the current watermark has no effect.
+func (ru *ReceiptUseCase) MarkRead(ctx context.Context, roomID, userID string, messageID int64) error {
+	if messageID <= 0 {
+		return newError(model.ErrCodeInvalid, "read_up_to must be a positive message id")
+	}
+	if err := ru.access.AuthorizeRead(ctx, roomID, userID); err != nil {
+		return err
+	}
+	msg, err := ru.messages.GetMessage(messageID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && msg.RoomID != roomID) {
+		return newError(model.ErrCodeNotFound, "message %d not found in room %s", messageID, roomID)
+	}
+	if err != nil {
+		log.Printf("[ReceiptUseCase] Failed to load message %d: %v\n", messageID, err)
+		return errInternal
+	}
+
+	advanced, err := ru.repo.AdvanceReadReceipt(&model.ReadReceipt{RoomID: roomID, UserID: userID, LastReadID: messageID})
+	if err != nil {
+		log.Printf("[ReceiptUseCase] Failed to save receipt of %s in room %s: %v\n", userID, roomID, err)
+		return errInternal
+	}
+	if !advanced {
+		return nil
+	}
+	if err := ru.cache.Advance(ctx, userID, map[string]int64{roomID: messageID}, false); err != nil {
+		log.Printf("[ReceiptUseCase] Failed to cache receipt of %s in room %s: %v\n", userID, roomID, err)
+	}
+
+	event := model.NewEvent(model.EventReadReceipt, roomID, userID)
+	_ = event.SetPayload(model.ReceiptPayload{UserID: userID, LastReadID: messageID})
+	if err := ru.pubSubRepo.Publish(ctx, redis.RoomTopic(roomID), event); err != nil {
+		log.Printf("[ReceiptUseCase] Failed to publish receipt of %s to room %s: %v\n", userID, roomID, err)
+	}
+	return nil
+}
+
+// UnreadCounts returns, for every room the user is a member of, how many messages from others
+// arrived after the user's watermark. Thread replies and deleted messages are not counted.
+func (ru *ReceiptUseCase) UnreadCounts(ctx context.Context, userID string) ([]model.UnreadCount, error) {
+	members, err := ru.rooms.ListMemberships(userID)
+	if err != nil {
+		log.Printf("[ReceiptUseCase] Failed to list rooms of %s: %v\n", userID, err)
+		return nil, errInternal
+	}
+	cached, err := ru.watermarks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	watermarks := make(map[string]int64, len(members))
+	for _, m := range members {
+		watermarks[m.RoomID] = cached[m.RoomID]
+	}
+	counts, err := ru.messages.CountUnread(userID, watermarks)
+	if err != nil {
+		log.Printf("[ReceiptUseCase] Failed to count unread messages of %s: %v\n", userID, err)
+		return nil, errInternal
+	}
+
+	unread := make([]model.UnreadCount, 0, len(members))
+	for _, m := range members {
+		unread = append(unread, model.UnreadCount{RoomID: m.RoomID, Unread: counts[m.RoomID], LastReadID: watermarks[m.RoomID]})
+	}
+	return unread, nil
+}
+
+// watermarks returns the user's watermarks from the cache, loading them from the database on a miss.
+func (ru *ReceiptUseCase) watermarks(ctx context.Context, userID string) (map[string]int64, error) {
+	cached, complete, err := ru.cache.Watermarks(ctx, userID)
+	if err == nil && complete {
+		return cached, nil
+	}
+	if err != nil {
+		log.Printf("[ReceiptUseCase] Failed to read cached receipts of %s: %v\n", userID, err)
+	}
+
+	receipts, err := ru.repo.ListReadReceipts(userID)
+	if err != nil {
+		log.Printf("[ReceiptUseCase] Failed to load receipts of %s: %v\n", userID, err)
+		return nil, errInternal
+	}
+	watermarks := make(map[string]int64, len(receipts))
+	for _, r := range receipts {
+		watermarks[r.RoomID] = r.LastReadID
+	}
+	if err := ru.cache.Advance(ctx, userID, watermarks, true); err != nil {
+		log.Printf("[ReceiptUseCase] Failed to cache receipts of %s: %v\n", userID, err)
+	}
+	return watermarks, nil
+}
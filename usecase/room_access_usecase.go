@@ -0,0 +1,273 @@
+// usecase/room_access_usecase.go
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"chat-websocket/model"
+	"chat-websocket/redis"
+	"chat-websocket/repository"
+)
+
+// RoomAccessUseCase decides who may join, read and post in a room, and manages room
+// creation, roles and invitations. Rooms that do not exist yet are created as public
+// rooms owned by the first user to join them; rooms that had messages before access control
+// are registered by migration without an owner. Banned users may not join and muted users
+// may not post.
+type RoomAccessUseCase struct {
+	repo       repository.RoomRepository
+	sanctions  repository.SanctionRepository
+	pubSubRepo redis.PubSubRepository
+}
+
+// NewRoomAccessUseCase creates a new RoomAccessUseCase instance.
+func NewRoomAccessUseCase(repo repository.RoomRepository, sanctions repository.SanctionRepository, pubSubRepo redis.PubSubRepository) *RoomAccessUseCase {
+	return &RoomAccessUseCase{repo: repo, sanctions: sanctions, pubSubRepo: pubSubRepo}
+}
+
+// CreateRoom creates a room with the given visibility owned by userID.
+func (au *RoomAccessUseCase) CreateRoom(ctx context.Context, roomID, userID, visibility string) (*model.RoomInfo, error) {
+	if visibility == "" {
+		visibility = model.VisibilityPublic
+	}
+	if !validVisibility(visibility) {
+		return nil, newError(model.ErrCodeInvalid, "unknown visibility %q", visibility)
+	}
+
+	room := &model.RoomInfo{ID: roomID, Visibility: visibility, OwnerID: userID}
+	if err := au.repo.CreateRoom(room); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, newError(model.ErrCodeConflict, "room %s already exists", roomID)
+		}
+		log.Printf("[RoomAccessUseCase] Failed to create room %s: %v\n", roomID, err)
+		return nil, errInternal
+	}
+	log.Printf("[RoomAccessUseCase] Room %s created by %s (%s)", roomID, userID, visibility)
+	return room, nil
+}
+
+// AuthorizeJoin checks that userID may join a room, creating unknown rooms and recording
+// the membership of users joining public rooms.
+func (au *RoomAccessUseCase) AuthorizeJoin(ctx context.Context, roomID, userID string) error {
+	room, err := au.repo.GetRoom(roomID)
+	if errors.Is(err, repository.ErrNotFound) {
+		room, err = au.CreateRoom(ctx, roomID, userID, model.VisibilityPublic)
+		if AsError(err).Code == model.ErrCodeConflict {
+			// Created concurrently by someone else; evaluate against their room.
+			room, err = au.repo.GetRoom(roomID)
+		}
+	}
+	if err != nil {
+		return au.internal(roomID, err)
+	}
+	if room.OwnerID == userID {
+		return nil
+	}
+	if err := au.checkSanction(roomID, userID, model.SanctionBan); err != nil {
+		return err
+	}
+
+	if _, err := au.member(roomID, userID); err == nil {
+		return nil
+	} else if AsError(err).Code != model.ErrCodeForbidden {
+		return err
+	}
+
+	switch room.Visibility {
+	case model.VisibilityPublic:
+		if err := au.repo.AddMember(&model.RoomMembership{RoomID: roomID, UserID: userID, Role: model.RoleMember}); err != nil {
+			return au.internal(roomID, err)
+		}
+		return nil
+	case model.VisibilityInviteOnly:
+		return newError(model.ErrCodeForbidden, "room %s requires an invitation", roomID)
+	default:
+		return errRoomNotFound(roomID)
+	}
+}
+
+// AuthorizePost checks that userID is a member of the room.
+func (au *RoomAccessUseCase) AuthorizePost(ctx context.Context, roomID, userID string) error {
+	if _, err := au.member(roomID, userID); err != nil {
+		if AsError(err).Code == model.ErrCodeForbidden {
+			return newError(model.ErrCodeForbidden, "join room %s before posting", roomID)
+		}
+		return err
+	}
+	return au.checkSanction(roomID, userID, model.SanctionMute)
+}
+
+// AuthorizeRead checks that userID may read a room's history and members. Public rooms
+// and rooms that have never been created are readable by anyone.
+func (au *RoomAccessUseCase) AuthorizeRead(ctx context.Context, roomID, userID string) error {
+	room, err := au.repo.GetRoom(roomID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return au.internal(roomID, err)
+	}
+	if room.Visibility == model.VisibilityPublic {
+		return nil
+	}
+
+	if _, err := au.member(roomID, userID); err != nil {
+		if AsError(err).Code != model.ErrCodeForbidden {
+			return err
+		}
+		if room.Visibility == model.VisibilityPrivate {
+			return errRoomNotFound(roomID)
+		}
+		return newError(model.ErrCodeForbidden, "room %s is for members only", roomID)
+	}
+	return nil
+}
+
+// Invite invites inviteeID into a room and notifies them on their user topic.
+// Any member may invite into public and invite-only rooms; private rooms need an owner or admin.
+func (au *RoomAccessUseCase) Invite(ctx context.Context, roomID, inviterID, inviteeID string) error {
+	if inviteeID == "" || inviteeID == inviterID {
+		return newError(model.ErrCodeInvalid, "invite needs another user_id")
+	}
+	room, err := au.room(roomID)
+	if err != nil {
+		return err
+	}
+	inviter, err := au.member(roomID, inviterID)
+	if err != nil {
+		if AsError(err).Code == model.ErrCodeForbidden && room.Visibility == model.VisibilityPrivate {
+			return errRoomNotFound(roomID)
+		}
+		return err
+	}
+	if room.Visibility == model.VisibilityPrivate && !model.CanManage(inviter.Role) {
+		return newError(model.ErrCodeForbidden, "only owners and admins may invite into room %s", roomID)
+	}
+	if _, err := au.repo.GetMember(roomID, inviteeID); err == nil {
+		return newError(model.ErrCodeConflict, "%s is already a member of room %s", inviteeID, roomID)
+	}
+	if err := au.checkSanction(roomID, inviteeID, model.SanctionBan); err != nil {
+		if AsError(err).Code != model.ErrCodeForbidden {
+			return err
+		}
+		return newError(model.ErrCodeConflict, "%s is banned from room %s", inviteeID, roomID)
+	}
+
+	inv := &model.RoomInvitation{RoomID: roomID, InviterID: inviterID, InviteeID: inviteeID}
+	if err := au.repo.SaveInvitation(inv); err != nil {
+		return au.internal(roomID, err)
+	}
+	log.Printf("[RoomAccessUseCase] %s invited %s into room %s", inviterID, inviteeID, roomID)
+
+	event := model.NewEvent(model.EventInvitation, roomID, inviterID)
+	_ = event.SetPayload(model.InvitationPayload{InviterID: inviterID})
+	if err := au.pubSubRepo.Publish(ctx, redis.UserTopic(inviteeID), event); err != nil {
+		log.Printf("[RoomAccessUseCase] Failed to notify %s of invitation to room %s: %v\n", inviteeID, roomID, err)
+	}
+	return nil
+}
+
+// AcceptInvitation makes userID a member of a room they were invited into.
+func (au *RoomAccessUseCase) AcceptInvitation(ctx context.Context, roomID, userID string) error {
+	if err := au.checkSanction(roomID, userID, model.SanctionBan); err != nil {
+		return err
+	}
+	if err := au.repo.AcceptInvitation(roomID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return newError(model.ErrCodeNotFound, "no pending invitation to room %s", roomID)
+		}
+		return au.internal(roomID, err)
+	}
+	log.Printf("[RoomAccessUseCase] %s accepted the invitation to room %s", userID, roomID)
+	return nil
+}
+
+// SetRole lets the room owner make a member an admin or a plain member.
+func (au *RoomAccessUseCase) SetRole(ctx context.Context, roomID, actorID, targetID, role string) error {
+	if role != model.RoleAdmin && role != model.RoleMember {
+		return newError(model.ErrCodeInvalid, "role must be %s or %s", model.RoleAdmin, model.RoleMember)
+	}
+	room, err := au.room(roomID)
+	if err != nil {
+		return err
+	}
+	if room.OwnerID != actorID {
+		return newError(model.ErrCodeForbidden, "only the owner may change roles in room %s", roomID)
+	}
+	if targetID == actorID {
+		return newError(model.ErrCodeInvalid, "the owner's role cannot be changed")
+	}
+	if err := au.repo.SetRole(roomID, targetID, role); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return newError(model.ErrCodeNotFound, "%s is not a member of room %s", targetID, roomID)
+		}
+		return au.internal(roomID, err)
+	}
+	log.Printf("[RoomAccessUseCase] %s is now %s in room %s", targetID, role, roomID)
+	return nil
+}
+
+// room loads a room, reporting a missing room as not_found.
+func (au *RoomAccessUseCase) room(roomID string) (*model.RoomInfo, error) {
+	room, err := au.repo.GetRoom(roomID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errRoomNotFound(roomID)
+	}
+	if err != nil {
+		return nil, au.internal(roomID, err)
+	}
+	return room, nil
+}
+
+// member loads a membership, reporting a missing one as forbidden.
+func (au *RoomAccessUseCase) member(roomID, userID string) (*model.RoomMembership, error) {
+	member, err := au.repo.GetMember(roomID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, newError(model.ErrCodeForbidden, "not a member of room %s", roomID)
+	}
+	if err != nil {
+		return nil, au.internal(roomID, err)
+	}
+	return member, nil
+}
+
+// checkSanction reports a forbidden error while the user is under a sanction of the given kind.
+func (au *RoomAccessUseCase) checkSanction(roomID, userID, kind string) error {
+	sanction, err := au.sanctions.GetActiveSanction(roomID, userID, kind, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return au.internal(roomID, err)
+	}
+
+	verb := "banned from"
+	if kind == model.SanctionMute {
+		verb = "muted in"
+	}
+	if sanction.ExpiresAt != nil {
+		return newError(model.ErrCodeForbidden, "you are %s room %s until %s", verb, roomID, sanction.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	return newError(model.ErrCodeForbidden, "you are %s room %s", verb, roomID)
+}
+
+// internal logs a storage failure and hides its details from the client.
+func (au *RoomAccessUseCase) internal(roomID string, err error) error {
+	var ue *Error
+	if errors.As(err, &ue) {
+		return ue
+	}
+	log.Printf("[RoomAccessUseCase] Storage error for room %s: %v\n", roomID, err)
+	return errInternal
+}
+
+func errRoomNotFound(roomID string) *Error {
+	return newError(model.ErrCodeNotFound, "room %s not found", roomID)
+}
+
+func validVisibility(v string) bool {
+	return v == model.VisibilityPublic || v == model.VisibilityInviteOnly || v == model.VisibilityPrivate
+}
@@ -3,122 +3,383 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
 	"log"
-	"strings"
 	"sync"
 
 	"chat-websocket/model"
 	"chat-websocket/redis"
-	"github.com/gorilla/websocket"
 )
 
 // RoomUseCase manages room operations such as join, leave, and local broadcasting.
+// Room membership is tracked per user with fan-out to each of the user's joined connections,
+// so a user is announced as joining with their first device and leaving with their last.
+// It also indexes the local connections of each user for user-addressed events, and the
+// local subscribers of each thread for thread traffic.
 type RoomUseCase struct {
 	pubSubRepo redis.PubSubRepository
+	presence   *PresenceUseCase
+	access     *RoomAccessUseCase
 	rooms      map[string]*model.Room
+	users      map[string]map[string]*model.Client // SenderID -> client ID -> client.
+	threads    map[int64]*model.Thread             // Keyed by parent message ID.
 	mutex      sync.RWMutex
 }
 
 // NewRoomUseCase creates a new RoomUseCase instance.
-func NewRoomUseCase(pubSubRepo redis.PubSubRepository) *RoomUseCase {
+func NewRoomUseCase(pubSubRepo redis.PubSubRepository, presence *PresenceUseCase, access *RoomAccessUseCase) *RoomUseCase {
 	return &RoomUseCase{
 		pubSubRepo: pubSubRepo,
+		presence:   presence,
+		access:     access,
 		rooms:      make(map[string]*model.Room),
+		users:      make(map[string]map[string]*model.Client),
+		threads:    make(map[int64]*model.Thread),
 	}
 }
 
-// startPubSubListener listens for cross-server messages via Redis Pub/Sub.
+// RegisterClient indexes a new connection under its user and, for the user's first
+// connection on this node, subscribes to the user's topic.
+func (uc *RoomUseCase) RegisterClient(ctx context.Context, client *model.Client) {
+	uc.mutex.Lock()
+	conns, exists := uc.users[client.SenderID]
+	if !exists {
+		conns = make(map[string]*model.Client)
+		uc.users[client.SenderID] = conns
+		userID := client.SenderID
+		uc.pubSubRepo.Subscribe(ctx, redis.UserTopic(userID), func(event *model.Event) {
+			uc.SendToLocalUser(userID, event)
+		})
+	}
+	conns[client.ID] = client
+	uc.mutex.Unlock()
+
+	uc.presence.Connect(ctx, client)
+}
+
+// SendToLocalUser sends an event to all of a user's connections on the local server.
+// An echoed room message skips the connections that receive it through the room or thread,
+// and a session_revoked event closes the revoked connection.
+func (uc *RoomUseCase) SendToLocalUser(userID string, event *model.Event) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[RoomUseCase] Failed to marshal event for user %s: %v", userID, err)
+		return
+	}
+
+	var revoked string
+	if event.Type == model.EventSessionRevoked {
+		var payload model.RevokePayload
+		if err := json.Unmarshal(event.Payload, &payload); err == nil {
+			revoked = payload.ConnID
+		}
+	}
+
+	uc.mutex.RLock()
+	defer uc.mutex.RUnlock()
+	for id, client := range uc.users[userID] {
+		if id == revoked {
+			log.Printf("[RoomUseCase] Client %s was revoked by its user, closing connection.", id)
+			client.CloseWith(model.CloseSessionRevoked, "session revoked")
+			continue
+		}
+		if event.Type == model.EventMessage && event.RoomID != "" && uc.followsLocked(client, event) {
+			continue
+		}
+		client.Send(message)
+	}
+}
+
+// followsLocked reports whether a client receives a room message through its room, or for a
+// reply through its thread. The caller must hold uc.mutex.
+func (uc *RoomUseCase) followsLocked(client *model.Client, event *model.Event) bool {
+	if event.ParentID != 0 {
+		thread, exists := uc.threads[event.ParentID]
+		if !exists {
+			return false
+		}
+		_, subscribed := thread.Clients[client.ID]
+		return subscribed
+	}
+	room, exists := uc.rooms[event.RoomID]
+	if !exists {
+		return false
+	}
+	room.Mutex.RLock()
+	defer room.Mutex.RUnlock()
+	_, member := room.Members[client.SenderID][client.ID]
+	return member
+}
+
+// InRoom reports whether a local client has joined a room.
+func (uc *RoomUseCase) InRoom(client *model.Client, roomName string) bool {
+	uc.mutex.RLock()
+	room, exists := uc.rooms[roomName]
+	uc.mutex.RUnlock()
+	if !exists {
+		return false
+	}
+	room.Mutex.RLock()
+	defer room.Mutex.RUnlock()
+	_, member := room.Members[client.SenderID][client.ID]
+	return member
+}
+
+// Memberships returns the rooms a local client has joined and the threads it is subscribed to.
+func (uc *RoomUseCase) Memberships(client *model.Client) ([]string, []int64) {
+	uc.mutex.RLock()
+	defer uc.mutex.RUnlock()
+
+	var rooms []string
+	for roomName, room := range uc.rooms {
+		room.Mutex.RLock()
+		if _, member := room.Members[client.SenderID][client.ID]; member {
+			rooms = append(rooms, roomName)
+		}
+		room.Mutex.RUnlock()
+	}
+	var threads []int64
+	for parentID, thread := range uc.threads {
+		if _, subscribed := thread.Clients[client.ID]; subscribed {
+			threads = append(threads, parentID)
+		}
+	}
+	return rooms, threads
+}
+
+// SubscribeThread delivers the replies to a room message, and changes to them, to the client.
+// The caller checks that the client's user can see the parent message.
+func (uc *RoomUseCase) SubscribeThread(ctx context.Context, client *model.Client, parent *model.Message) {
+	uc.mutex.Lock()
+	defer uc.mutex.Unlock()
+
+	thread, exists := uc.threads[parent.ID]
+	if !exists {
+		thread = &model.Thread{ParentID: parent.ID, RoomID: parent.RoomID, Clients: make(map[string]*model.Client)}
+		uc.threads[parent.ID] = thread
+		parentID := parent.ID
+		uc.pubSubRepo.Subscribe(ctx, redis.ThreadTopic(parentID), func(event *model.Event) {
+			uc.SendToLocalThread(parentID, event)
+		})
+	}
+	thread.Clients[client.ID] = client
+	log.Printf("[RoomUseCase] Client %s subscribed to thread %d", client.ID, parent.ID)
+}
+
+// UnsubscribeThread stops delivering a thread's traffic to the client.
+func (uc *RoomUseCase) UnsubscribeThread(ctx context.Context, clientID string, parentID int64) {
+	uc.mutex.Lock()
+	defer uc.mutex.Unlock()
+	uc.unsubscribeThreadLocked(ctx, clientID, parentID)
+}
+
+// unsubscribeThreadLocked removes a client from a thread, dropping the thread's subscription
+// once no local client is left. The caller must hold uc.mutex.
+func (uc *RoomUseCase) unsubscribeThreadLocked(ctx context.Context, clientID string, parentID int64) {
+	thread, exists := uc.threads[parentID]
+	if !exists {
+		return
+	}
+	delete(thread.Clients, clientID)
+	if len(thread.Clients) == 0 {
+		delete(uc.threads, parentID)
+		uc.pubSubRepo.Unsubscribe(ctx, redis.ThreadTopic(parentID))
+	}
+}
+
+// SendToLocalThread sends an event to the local subscribers of a thread.
+func (uc *RoomUseCase) SendToLocalThread(parentID int64, event *model.Event) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[RoomUseCase] Failed to marshal event for thread %d: %v", parentID, err)
+		return
+	}
+
+	uc.mutex.RLock()
+	defer uc.mutex.RUnlock()
+	if thread, exists := uc.threads[parentID]; exists {
+		for _, client := range thread.Clients {
+			client.Send(message)
+		}
+	}
+}
+
+// startPubSubListener registers the room with the node's Pub/Sub dispatcher.
 func (uc *RoomUseCase) startPubSubListener(roomName string) {
-	go uc.pubSubRepo.Subscribe(context.Background(), roomName, func(payload []byte) {
-		parts := strings.SplitN(string(payload), "|", 2)
-		if len(parts) != 2 {
-			log.Printf("[RoomUseCase] Invalid broadcast for room %s: %s", roomName, payload)
-			return
+	uc.pubSubRepo.Subscribe(context.Background(), redis.RoomTopic(roomName), func(event *model.Event) {
+		uc.BroadcastToLocalRoom(roomName, event)
+		if event.Type == model.EventModeration {
+			// Leaving unsubscribes and publishes, which must not happen on the dispatcher.
+			go uc.applyModeration(roomName, event)
 		}
-		uc.BroadcastToLocalRoom(roomName, parts[1])
 	})
 	log.Printf("[RoomUseCase] Started PubSub listener for room %s", roomName)
 }
 
-// JoinRoom adds a client to a room and publishes a join message.
-func (uc *RoomUseCase) JoinRoom(ctx context.Context, client *model.Client, roomName string) {
+// JoinRoom adds a client to a room and, if no other connection of its user is in the room
+// anywhere in the cluster, publishes a join message.
+// It fails if the client's user may not join the room.
+func (uc *RoomUseCase) JoinRoom(ctx context.Context, client *model.Client, roomName string) error {
+	if err := uc.access.AuthorizeJoin(ctx, roomName, client.SenderID); err != nil {
+		log.Printf("[RoomUseCase] Client %s may not join room %s: %v", client.ID, roomName, err)
+		return err
+	}
+
 	uc.mutex.Lock()
 	room, exists := uc.rooms[roomName]
 	if !exists {
 		room = &model.Room{
 			Name:    roomName,
-			Clients: make(map[string]*model.ClientConn),
+			Members: make(map[string]map[string]*model.Client),
 		}
 		uc.rooms[roomName] = room
 		uc.startPubSubListener(roomName)
 	}
 	room.Mutex.Lock()
-	room.Clients[client.ID] = &model.ClientConn{
-		ID:   client.ID,
-		Conn: client,
+	conns, member := room.Members[client.SenderID]
+	if !member {
+		conns = make(map[string]*model.Client)
+		room.Members[client.SenderID] = conns
 	}
+	conns[client.ID] = client
 	room.Mutex.Unlock()
 	uc.mutex.Unlock()
 
 	log.Printf("[RoomUseCase] Client %s joined room %s", client.ID, roomName)
-	_ = uc.pubSubRepo.Publish(ctx, roomName, roomName+"|"+client.ID+" joined the room")
+	uc.presence.JoinedRoom(ctx, client, roomName)
+	if !member && !uc.presentElsewhere(ctx, client, roomName) {
+		_ = uc.pubSubRepo.Publish(ctx, redis.RoomTopic(roomName), newMembershipEvent(model.EventJoin, roomName, client))
+	}
+	return nil
 }
 
-// LeaveRoom removes a client from the specified room and publishes a leave message.
-func (uc *RoomUseCase) LeaveRoom(ctx context.Context, clientID, roomName string) {
-	uc.mutex.RLock()
+// LeaveRoom removes a client from the specified room and, if it was the last connection of its
+// user in the room anywhere in the cluster, publishes a leave message. The user's other
+// connections stay in the room.
+func (uc *RoomUseCase) LeaveRoom(ctx context.Context, client *model.Client, roomName string) {
+	// Hold the rooms lock across the unsubscribe so a concurrent JoinRoom cannot
+	// re-create the room and have its fresh subscription torn down.
+	uc.mutex.Lock()
 	room, exists := uc.rooms[roomName]
-	uc.mutex.RUnlock()
 	if !exists {
+		uc.mutex.Unlock()
 		log.Printf("[RoomUseCase] Room %s does not exist", roomName)
 		return
 	}
 
 	room.Mutex.Lock()
-	delete(room.Clients, clientID)
-	empty := len(room.Clients) == 0
+	member, last := removeMemberLocked(room, client)
+	empty := len(room.Members) == 0
 	room.Mutex.Unlock()
 
 	if empty {
-		uc.mutex.Lock()
 		delete(uc.rooms, roomName)
-		uc.mutex.Unlock()
-		uc.pubSubRepo.Unsubscribe(ctx, roomName)
+		uc.pubSubRepo.Unsubscribe(ctx, redis.RoomTopic(roomName))
+	}
+	uc.mutex.Unlock()
+
+	if !member {
+		log.Printf("[RoomUseCase] Client %s is not in room %s", client.ID, roomName)
+		return
 	}
 
-	log.Printf("[RoomUseCase] Client %s left room %s", clientID, roomName)
-	_ = uc.pubSubRepo.Publish(ctx, roomName, roomName+"|"+clientID+" left the room")
+	log.Printf("[RoomUseCase] Client %s left room %s", client.ID, roomName)
+	uc.presence.LeftRoom(ctx, client, roomName)
+	if last && !uc.presentElsewhere(ctx, client, roomName) {
+		_ = uc.pubSubRepo.Publish(ctx, redis.RoomTopic(roomName), newMembershipEvent(model.EventLeave, roomName, client))
+	}
 }
 
-// RemoveClient removes a client from all rooms.
-func (uc *RoomUseCase) RemoveClient(ctx context.Context, clientID string) {
+// RemoveClient removes a client from all rooms and from its user's connection index.
+func (uc *RoomUseCase) RemoveClient(ctx context.Context, client *model.Client) {
 	uc.mutex.Lock()
-	defer uc.mutex.Unlock()
 
+	clientID := client.ID
+	var lastIn []string // Rooms where this was the user's last local connection.
 	for roomName, room := range uc.rooms {
 		room.Mutex.Lock()
-		if _, exists := room.Clients[clientID]; exists {
-			delete(room.Clients, clientID)
+		if member, last := removeMemberLocked(room, client); member {
 			log.Printf("[RoomUseCase] Client %s removed from room %s", clientID, roomName)
-			if len(room.Clients) == 0 {
+			if last {
+				lastIn = append(lastIn, roomName)
+			}
+			if len(room.Members) == 0 {
 				delete(uc.rooms, roomName)
-				uc.pubSubRepo.Unsubscribe(ctx, roomName)
+				uc.pubSubRepo.Unsubscribe(ctx, redis.RoomTopic(roomName))
 			}
-			_ = uc.pubSubRepo.Publish(ctx, roomName, roomName+"|"+clientID+" left the room")
 		}
 		room.Mutex.Unlock()
 	}
+
+	for parentID, thread := range uc.threads {
+		if _, exists := thread.Clients[clientID]; exists {
+			uc.unsubscribeThreadLocked(ctx, clientID, parentID)
+		}
+	}
+
+	if conns, exists := uc.users[client.SenderID]; exists {
+		delete(conns, clientID)
+		if len(conns) == 0 {
+			delete(uc.users, client.SenderID)
+			uc.pubSubRepo.Unsubscribe(ctx, redis.UserTopic(client.SenderID))
+		}
+	}
+	uc.mutex.Unlock()
+
+	uc.presence.Disconnect(ctx, client)
+	for _, roomName := range lastIn {
+		if !uc.presentElsewhere(ctx, client, roomName) {
+			_ = uc.pubSubRepo.Publish(ctx, redis.RoomTopic(roomName), newMembershipEvent(model.EventLeave, roomName, client))
+		}
+	}
+}
+
+// removeMemberLocked removes a client from a room, reporting whether it was in the room and
+// whether it was its user's last local connection there. The caller must hold room.Mutex.
+func removeMemberLocked(room *model.Room, client *model.Client) (member, last bool) {
+	conns := room.Members[client.SenderID]
+	if _, member = conns[client.ID]; !member {
+		return false, false
+	}
+	delete(conns, client.ID)
+	if len(conns) == 0 {
+		delete(room.Members, client.SenderID)
+		return true, true
+	}
+	return true, false
+}
+
+// presentElsewhere reports whether another connection of the client's user, on any node, is in
+// the room according to presence. If presence is unavailable it reports false, so membership
+// changes are announced rather than lost.
+func (uc *RoomUseCase) presentElsewhere(ctx context.Context, client *model.Client, roomName string) bool {
+	_, conns, err := uc.presence.UserPresence(ctx, client.SenderID)
+	if err != nil {
+		log.Printf("[RoomUseCase] Failed to load presence of %s: %v", client.SenderID, err)
+		return false
+	}
+	for _, c := range conns {
+		if c.ConnID == client.ID {
+			continue
+		}
+		for _, room := range c.Rooms {
+			if room == roomName {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-// BroadcastMessage broadcasts a message to all servers via Redis.
-func (uc *RoomUseCase) BroadcastMessage(ctx context.Context, roomName, message string) {
-	if err := uc.pubSubRepo.Publish(ctx, roomName, roomName+"|"+message); err != nil {
+// BroadcastMessage broadcasts an event to all servers via Redis.
+func (uc *RoomUseCase) BroadcastMessage(ctx context.Context, roomName string, event *model.Event) {
+	if err := uc.pubSubRepo.Publish(ctx, redis.RoomTopic(roomName), event); err != nil {
 		log.Printf("[RoomUseCase] Failed to broadcast message to room %s: %v", roomName, err)
 	}
 }
 
-// BroadcastToLocalRoom sends a message to all clients in the room on the local server.
-func (uc *RoomUseCase) BroadcastToLocalRoom(roomName, message string) {
+// BroadcastToLocalRoom sends an event to all clients in the room on the local server.
+func (uc *RoomUseCase) BroadcastToLocalRoom(roomName string, event *model.Event) {
 	uc.mutex.RLock()
 	room, exists := uc.rooms[roomName]
 	uc.mutex.RUnlock()
@@ -127,19 +388,65 @@ func (uc *RoomUseCase) BroadcastToLocalRoom(roomName, message string) {
 		return
 	}
 
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[RoomUseCase] Failed to marshal event for room %s: %v", roomName, err)
+		return
+	}
+
 	room.Mutex.RLock()
 	defer room.Mutex.RUnlock()
 
-	for _, conn := range room.Clients {
-		go func(cc *model.ClientConn) {
-			cc.Conn.Mutex.Lock()
-			defer cc.Conn.Mutex.Unlock()
-			if cc.Conn.Conn == nil {
-				return
-			}
-			if err := cc.Conn.Conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
-				log.Printf("Failed to send message to client %s: %v", cc.ID, err)
+	for _, conns := range room.Members {
+		for _, client := range conns {
+			client.SendEvent(event, message)
+		}
+	}
+}
+
+// applyModeration removes the local connections of a kicked or banned user from the room
+// and its threads.
+func (uc *RoomUseCase) applyModeration(roomName string, event *model.Event) {
+	var payload model.ModerationPayload
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		log.Printf("[RoomUseCase] Invalid moderation event for room %s: %v", roomName, err)
+		return
+	}
+	if payload.Action != model.ModerationKick && payload.Action != model.ModerationBan {
+		return
+	}
+
+	var clients []*model.Client
+	uc.mutex.Lock()
+	if room, exists := uc.rooms[roomName]; exists {
+		room.Mutex.RLock()
+		for _, client := range room.Members[payload.UserID] {
+			clients = append(clients, client)
+		}
+		room.Mutex.RUnlock()
+	}
+	// Threads of the room follow the room: the user's subscriptions to them end too.
+	for parentID, thread := range uc.threads {
+		if thread.RoomID != roomName {
+			continue
+		}
+		for id, client := range thread.Clients {
+			if client.SenderID == payload.UserID {
+				uc.unsubscribeThreadLocked(context.Background(), id, parentID)
 			}
-		}(conn)
+		}
+	}
+	uc.mutex.Unlock()
+
+	for _, client := range clients {
+		log.Printf("[RoomUseCase] Removing client %s from room %s (%s)", client.ID, roomName, payload.Action)
+		uc.LeaveRoom(context.Background(), client, roomName)
 	}
 }
+
+// newMembershipEvent builds a join or leave event for the given client.
+func newMembershipEvent(eventType, roomName string, client *model.Client) *model.Event {
+	event := model.NewEvent(eventType, roomName, client.SenderID)
+	_ = event.SetPayload(model.MembershipPayload{ClientID: client.ID})
+	return event
+}
@@ -0,0 +1,233 @@
+// usecase/room_usecase_test.go
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chat-websocket/model"
+	"chat-websocket/redis"
+	"chat-websocket/repository"
+	"chat-websocket/service"
+
+	"github.com/gorilla/websocket"
+)
+
+// settle is how long a test waits for events to arrive, or to make sure they do not.
+const settle = 200 * time.Millisecond
+
+// testEnv wires the use cases to the in-memory backends.
+type testEnv struct {
+	pubSub    redis.PubSubRepository
+	sanctions repository.SanctionRepository
+	roomRepo  repository.RoomRepository
+	presence  *PresenceUseCase
+	access    *RoomAccessUseCase
+	rooms     *RoomUseCase
+	typing    *TypingUseCase
+	inbox     *InboxUseCase
+	messages  *MessageUseCase
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	pubSub := redis.NewMemoryPubSubRepository()
+	t.Cleanup(func() { _ = pubSub.Close() })
+
+	env := &testEnv{
+		pubSub:    pubSub,
+		sanctions: repository.NewMemorySanctionRepository(),
+		roomRepo:  repository.NewMemoryRoomRepository(),
+	}
+	env.presence = NewPresenceUseCase(redis.NewMemoryPresenceRepository(), pubSub, "test", time.Minute, time.Second)
+	env.access = NewRoomAccessUseCase(env.roomRepo, env.sanctions, pubSub)
+	env.rooms = NewRoomUseCase(pubSub, env.presence, env.access)
+	env.typing = NewTypingUseCase(pubSub, env.rooms, env.access, time.Minute)
+	env.inbox = NewInboxUseCase(redis.NewMemoryInboxStore(100), repository.NewMemoryInboxRepository(), env.presence, time.Hour)
+	env.messages = NewMessageUseCase(repository.NewMemoryMessageRepository(), service.NewMessageService(pubSub), env.access, env.inbox)
+	return env
+}
+
+// testClient is a connected client whose received events are collected from the peer end of
+// its WebSocket.
+type testClient struct {
+	*model.Client
+	events chan model.Event
+}
+
+// connect registers a new connection of userID with the environment's RoomUseCase.
+func (env *testEnv) connect(t *testing.T, id, userID string) *testClient {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = peer.Close() })
+
+	client := model.NewClient(id, <-conns, model.ClientOptions{})
+	client.SenderID = userID
+	go client.WritePump()
+	t.Cleanup(client.Close)
+
+	tc := &testClient{Client: client, events: make(chan model.Event, 100)}
+	go func() {
+		for {
+			var event model.Event
+			if err := peer.ReadJSON(&event); err != nil {
+				return
+			}
+			tc.events <- event
+		}
+	}()
+	env.rooms.RegisterClient(context.Background(), client)
+	return tc
+}
+
+// received returns the types of the events of the given types received within settle.
+func (tc *testClient) received(types ...string) []string {
+	wanted := make(map[string]bool)
+	for _, typ := range types {
+		wanted[typ] = true
+	}
+	var got []string
+	timeout := time.After(settle)
+	for {
+		select {
+		case event := <-tc.events:
+			if wanted[event.Type] {
+				got = append(got, event.Type)
+			}
+		case <-timeout:
+			return got
+		}
+	}
+}
+
+func TestRoomUseCaseMembershipEvents(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(ctx context.Context, env *testEnv, phone, laptop *model.Client)
+		want []string // Membership events seen by another member of the room.
+	}{
+		{
+			name: "first connection joins",
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) {
+				_ = env.rooms.JoinRoom(ctx, phone, "lobby")
+			},
+			want: []string{model.EventJoin},
+		},
+		{
+			name: "second device joins silently",
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) {
+				_ = env.rooms.JoinRoom(ctx, phone, "lobby")
+				_ = env.rooms.JoinRoom(ctx, laptop, "lobby")
+			},
+			want: []string{model.EventJoin},
+		},
+		{
+			name: "last connection leaves",
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) {
+				_ = env.rooms.JoinRoom(ctx, phone, "lobby")
+				env.rooms.LeaveRoom(ctx, phone, "lobby")
+			},
+			want: []string{model.EventJoin, model.EventLeave},
+		},
+		{
+			name: "user stays while another device is in the room",
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) {
+				_ = env.rooms.JoinRoom(ctx, phone, "lobby")
+				_ = env.rooms.JoinRoom(ctx, laptop, "lobby")
+				env.rooms.LeaveRoom(ctx, phone, "lobby")
+			},
+			want: []string{model.EventJoin},
+		},
+		{
+			name: "disconnect leaves the rooms",
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) {
+				_ = env.rooms.JoinRoom(ctx, phone, "lobby")
+				env.rooms.RemoveClient(ctx, phone)
+			},
+			want: []string{model.EventJoin, model.EventLeave},
+		},
+		{
+			name: "leaving a room not joined is ignored",
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) {
+				env.rooms.LeaveRoom(ctx, phone, "lobby")
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			env := newTestEnv(t)
+			observer := env.connect(t, "bob-1", "bob")
+			if err := env.rooms.JoinRoom(ctx, observer.Client, "lobby"); err != nil {
+				t.Fatalf("observer join: %v", err)
+			}
+			observer.received()
+
+			phone := env.connect(t, "alice-1", "alice")
+			laptop := env.connect(t, "alice-2", "alice")
+			tt.run(ctx, env, phone.Client, laptop.Client)
+
+			got := observer.received(model.EventJoin, model.EventLeave)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("membership events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoomUseCaseBroadcast(t *testing.T) {
+	tests := []struct {
+		name   string
+		joined bool
+		want   bool
+	}{
+		{name: "member receives room messages", joined: true, want: true},
+		{name: "non-member does not", joined: false, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			env := newTestEnv(t)
+			sender := env.connect(t, "bob-1", "bob")
+			if err := env.rooms.JoinRoom(ctx, sender.Client, "lobby"); err != nil {
+				t.Fatalf("sender join: %v", err)
+			}
+			client := env.connect(t, "alice-1", "alice")
+			if tt.joined {
+				if err := env.rooms.JoinRoom(ctx, client.Client, "lobby"); err != nil {
+					t.Fatalf("join: %v", err)
+				}
+			}
+			client.received()
+
+			msg := &model.Message{ID: 1, RoomID: "lobby", SenderID: "bob", Content: "hello"}
+			env.rooms.BroadcastMessage(ctx, "lobby", model.NewMessageEvent(msg))
+
+			got := len(client.received(model.EventMessage)) > 0
+			if got != tt.want {
+				t.Errorf("received message = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+// usecase/session_usecase.go
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"chat-websocket/model"
+	"chat-websocket/redis"
+)
+
+// SessionUseCase issues a resumable session to every connection. It tracks, per joined room,
+// the last message the client acknowledged, and saves the session as it changes and when the
+// connection closes, so that a reconnect within the resume window can rejoin the rooms and
+// replay what was missed, even before the server notices the old connection is gone.
+// It also lists a user's connected devices and lets the user revoke one.
+type SessionUseCase struct {
+	store      redis.SessionStore
+	rooms      *RoomUseCase
+	presence   *PresenceUseCase
+	pubSubRepo redis.PubSubRepository
+	window     time.Duration
+
+	mutex    sync.Mutex
+	sessions map[string]*liveSession // Client ID -> live session.
+}
+
+// liveSession is the session of a connection on this node.
+type liveSession struct {
+	client  *model.Client
+	session *model.Session
+}
+
+// NewSessionUseCase creates a new SessionUseCase instance.
+func NewSessionUseCase(store redis.SessionStore, rooms *RoomUseCase, presence *PresenceUseCase, pubSubRepo redis.PubSubRepository, window time.Duration) *SessionUseCase {
+	return &SessionUseCase{
+		store:      store,
+		rooms:      rooms,
+		presence:   presence,
+		pubSubRepo: pubSubRepo,
+		window:     window,
+		sessions:   make(map[string]*liveSession),
+	}
+}
+
+// Window returns how long a closed connection's session can be resumed.
+func (su *SessionUseCase) Window() time.Duration {
+	return su.window
+}
+
+// Run keeps the saved sessions of live connections from expiring until ctx is done.
+func (su *SessionUseCase) Run(ctx context.Context) {
+	if su.window <= 0 {
+		return
+	}
+	ticker := time.NewTicker(su.window / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			su.mutex.Lock()
+			clients := make([]*model.Client, 0, len(su.sessions))
+			for _, live := range su.sessions {
+				clients = append(clients, live.client)
+			}
+			su.mutex.Unlock()
+			for _, client := range clients {
+				su.Sync(ctx, client)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Start issues a new session to a connection and saves it.
+func (su *SessionUseCase) Start(ctx context.Context, client *model.Client) (*model.Session, error) {
+	token, err := newSessionToken()
+	if err != nil {
+		log.Printf("[SessionUseCase] Failed to generate a session token for client %s: %v", client.ID, err)
+		return nil, errInternal
+	}
+	session := &model.Session{Token: token, UserID: client.SenderID, ConnID: client.ID, Rooms: make(map[string]int64)}
+	if err := su.store.Save(ctx, session, su.window); err != nil {
+		log.Printf("[SessionUseCase] Failed to save session of client %s: %v", client.ID, err)
+	}
+
+	su.mutex.Lock()
+	su.sessions[client.ID] = &liveSession{client: client, session: session}
+	su.mutex.Unlock()
+	return session, nil
+}
+
+// Resume returns the saved session of a connection of the user and forgets it, so it can be
+// resumed only once. If that connection is still open, for instance because the server has not
+// yet noticed that the network dropped it, it is closed. The caller restores the session's rooms
+// and threads.
+func (su *SessionUseCase) Resume(ctx context.Context, userID, token string) (*model.Session, error) {
+	session, err := su.store.Take(ctx, token)
+	if err != nil {
+		log.Printf("[SessionUseCase] Failed to load session for %s: %v", userID, err)
+		return nil, errInternal
+	}
+	if session == nil || session.UserID != userID {
+		return nil, newError(model.ErrCodeNotFound, "session not found or expired")
+	}
+	log.Printf("[SessionUseCase] Resuming session of %s with %d rooms", userID, len(session.Rooms))
+
+	if _, conns, err := su.presence.UserPresence(ctx, userID); err == nil {
+		for _, c := range conns {
+			if c.ConnID == session.ConnID {
+				log.Printf("[SessionUseCase] Session of %s resumed while connection %s is open, closing it", userID, c.ConnID)
+				su.publishRevoke(ctx, userID, c.ConnID)
+			}
+		}
+	}
+	return session, nil
+}
+
+// Advance records that the client has received the messages of a room up to lastID, and saves
+// the session. It has no effect on rooms the client has not joined or when lastID is older than
+// the recorded one.
+func (su *SessionUseCase) Advance(ctx context.Context, client *model.Client, roomID string, lastID int64) error {
+	if lastID <= 0 {
+		return newError(model.ErrCodeInvalid, "last_id must be a positive message id")
+	}
+	if !su.rooms.InRoom(client, roomID) {
+		return nil
+	}
+
+	su.mutex.Lock()
+	live, exists := su.sessions[client.ID]
+	advanced := exists && lastID > live.session.Rooms[roomID]
+	if advanced {
+		live.session.Rooms[roomID] = lastID
+	}
+	su.mutex.Unlock()
+	if advanced {
+		su.Sync(ctx, client)
+	}
+	return nil
+}
+
+// Sync saves the session of a live connection with the rooms and threads it is in now.
+// A session that has been resumed elsewhere is not saved again.
+func (su *SessionUseCase) Sync(ctx context.Context, client *model.Client) {
+	saved := su.snapshot(client)
+	if saved == nil {
+		return
+	}
+	if _, err := su.store.Update(ctx, saved, su.window); err != nil {
+		log.Printf("[SessionUseCase] Failed to save session of client %s: %v", client.ID, err)
+	}
+}
+
+// snapshot returns the resumable state of a live connection, or nil if it has no session.
+// Rooms left, or lost to a kick or ban, since they were acknowledged are not included.
+func (su *SessionUseCase) snapshot(client *model.Client) *model.Session {
+	rooms, threads := su.rooms.Memberships(client)
+
+	su.mutex.Lock()
+	defer su.mutex.Unlock()
+	live, exists := su.sessions[client.ID]
+	if !exists {
+		return nil
+	}
+	session := live.session
+	saved := &model.Session{Token: session.Token, UserID: session.UserID, ConnID: session.ConnID, Rooms: make(map[string]int64, len(rooms)), Threads: threads}
+	for _, roomID := range rooms {
+		saved.Rooms[roomID] = session.Rooms[roomID]
+	}
+	return saved
+}
+
+// Disconnect saves the session of a closing connection for the resume window, unless the
+// connection was revoked or its session resumed elsewhere. It must run before the client is
+// removed from its rooms.
+func (su *SessionUseCase) Disconnect(ctx context.Context, client *model.Client) {
+	if client.CloseCode() != model.CloseSessionRevoked {
+		su.Sync(ctx, client)
+	}
+	su.mutex.Lock()
+	delete(su.sessions, client.ID)
+	su.mutex.Unlock()
+}
+
+// Devices returns the live connections of a user across the cluster, oldest first.
+func (su *SessionUseCase) Devices(ctx context.Context, userID string) ([]model.Presence, error) {
+	_, conns, err := su.presence.UserPresence(ctx, userID)
+	if err != nil {
+		log.Printf("[SessionUseCase] Failed to load connections of %s: %v", userID, err)
+		return nil, errInternal
+	}
+	sort.Slice(conns, func(i, j int) bool { return conns[i].ConnectedAt.Before(conns[j].ConnectedAt) })
+	if conns == nil {
+		conns = []model.Presence{}
+	}
+	return conns, nil
+}
+
+// Revoke disconnects one of the user's connections, wherever it is, and tells the user's other
+// connections. The revoked connection's session cannot be resumed.
+func (su *SessionUseCase) Revoke(ctx context.Context, userID, connID string) error {
+	conns, err := su.Devices(ctx, userID)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, c := range conns {
+		found = found || c.ConnID == connID
+	}
+	if !found {
+		return newError(model.ErrCodeNotFound, "connection %s not found", connID)
+	}
+
+	if err := su.publishRevoke(ctx, userID, connID); err != nil {
+		return errInternal
+	}
+	log.Printf("[SessionUseCase] %s revoked connection %s", userID, connID)
+	return nil
+}
+
+// publishRevoke tells the node holding a connection of the user to close it as revoked, and the
+// user's other connections that it was.
+func (su *SessionUseCase) publishRevoke(ctx context.Context, userID, connID string) error {
+	event := model.NewEvent(model.EventSessionRevoked, "", userID)
+	_ = event.SetPayload(model.RevokePayload{ConnID: connID})
+	if err := su.pubSubRepo.Publish(ctx, redis.UserTopic(userID), event); err != nil {
+		log.Printf("[SessionUseCase] Failed to revoke connection %s of %s: %v", connID, userID, err)
+		return err
+	}
+	return nil
+}
+
+// newSessionToken returns a random, unguessable session token.
+func newSessionToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
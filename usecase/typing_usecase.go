@@ -0,0 +1,183 @@
+// usecase/typing_usecase.go
+package usecase
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"chat-websocket/model"
+	"chat-websocket/redis"
+)
+
+// TypingUseCase relays typing indicators to rooms. Indicators are ephemeral: they are never
+// stored, expire after ttl unless refreshed, and repeated starts are coalesced so that a user
+// is announced typing in a room at most once per half ttl, however many devices they type on.
+// A user stops typing in a room once none of their local connections is typing there; devices
+// on other nodes keep their indicator alive by re-announcing it every half ttl.
+type TypingUseCase struct {
+	pubSubRepo redis.PubSubRepository
+	rooms      *RoomUseCase
+	access     *RoomAccessUseCase
+	ttl        time.Duration
+
+	mutex  sync.Mutex
+	typing map[typingKey]*typingState
+}
+
+// typingKey identifies one user typing in one room.
+type typingKey struct {
+	roomID string
+	userID string
+}
+
+type typingState struct {
+	announced time.Time            // When typing was last published.
+	conns     map[string]time.Time // Local connection ID -> when its indicator expires.
+}
+
+// NewTypingUseCase creates a new TypingUseCase instance.
+func NewTypingUseCase(pubSubRepo redis.PubSubRepository, rooms *RoomUseCase, access *RoomAccessUseCase, ttl time.Duration) *TypingUseCase {
+	return &TypingUseCase{
+		pubSubRepo: pubSubRepo,
+		rooms:      rooms,
+		access:     access,
+		ttl:        ttl,
+		typing:     make(map[typingKey]*typingState),
+	}
+}
+
+// Run stops the indicators whose connections stopped refreshing them until ctx is done.
+func (tu *TypingUseCase) Run(ctx context.Context) {
+	ticker := time.NewTicker(tu.ttl / 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			tu.expire(ctx, time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Start marks the client as typing in a room it has joined. Starts from clients that are not
+// in the room are ignored; users who may not post there, such as muted users, are refused.
+func (tu *TypingUseCase) Start(ctx context.Context, client *model.Client, roomID string) error {
+	if !tu.rooms.InRoom(client, roomID) {
+		return nil
+	}
+
+	now := time.Now()
+	key := typingKey{roomID: roomID, userID: client.SenderID}
+	tu.mutex.Lock()
+	if state, exists := tu.typing[key]; exists && now.Sub(state.announced) < tu.ttl/2 {
+		// Announced recently; only refresh this connection's indicator.
+		state.conns[client.ID] = now.Add(tu.ttl)
+		tu.mutex.Unlock()
+		return nil
+	}
+	tu.mutex.Unlock()
+
+	// Checked only when announcing, which happens at most once per half ttl.
+	if err := tu.access.AuthorizePost(ctx, roomID, client.SenderID); err != nil {
+		return err
+	}
+
+	tu.mutex.Lock()
+	state, exists := tu.typing[key]
+	if !exists {
+		state = &typingState{conns: make(map[string]time.Time)}
+		tu.typing[key] = state
+	}
+	state.conns[client.ID] = now.Add(tu.ttl)
+	state.announced = now
+	tu.mutex.Unlock()
+
+	tu.publish(ctx, roomID, client.SenderID, true)
+	return nil
+}
+
+// Stop clears the client's typing indicator in a room, if it is set. The user is announced as
+// stopped only if none of their other connections is typing there.
+func (tu *TypingUseCase) Stop(ctx context.Context, client *model.Client, roomID string) {
+	key := typingKey{roomID: roomID, userID: client.SenderID}
+	tu.mutex.Lock()
+	stopped := tu.removeLocked(key, client.ID)
+	tu.mutex.Unlock()
+
+	if stopped {
+		tu.publish(ctx, roomID, client.SenderID, false)
+	}
+}
+
+// Disconnect clears all typing indicators of a closing connection.
+func (tu *TypingUseCase) Disconnect(ctx context.Context, client *model.Client) {
+	var rooms []string
+	tu.mutex.Lock()
+	for key := range tu.typing {
+		if key.userID == client.SenderID && tu.removeLocked(key, client.ID) {
+			rooms = append(rooms, key.roomID)
+		}
+	}
+	tu.mutex.Unlock()
+
+	for _, roomID := range rooms {
+		tu.publish(ctx, roomID, client.SenderID, false)
+	}
+}
+
+// removeLocked clears one connection's indicator and reports whether that was the user's last
+// one in the room; tu.mutex must be held.
+func (tu *TypingUseCase) removeLocked(key typingKey, clientID string) bool {
+	state, exists := tu.typing[key]
+	if !exists {
+		return false
+	}
+	if _, typing := state.conns[clientID]; !typing {
+		return false
+	}
+	delete(state.conns, clientID)
+	if len(state.conns) > 0 {
+		return false
+	}
+	delete(tu.typing, key)
+	return true
+}
+
+// expire clears the indicators that were not refreshed within ttl.
+func (tu *TypingUseCase) expire(ctx context.Context, now time.Time) {
+	var stops []typingKey
+	tu.mutex.Lock()
+	for key, state := range tu.typing {
+		for clientID, expires := range state.conns {
+			if now.After(expires) {
+				delete(state.conns, clientID)
+			}
+		}
+		if len(state.conns) == 0 {
+			stops = append(stops, key)
+			delete(tu.typing, key)
+		}
+	}
+	tu.mutex.Unlock()
+
+	for _, key := range stops {
+		tu.publish(ctx, key.roomID, key.userID, false)
+	}
+}
+
+// publish sends a typing event for userID into a room.
+func (tu *TypingUseCase) publish(ctx context.Context, roomID, userID string, typing bool) {
+	event := model.NewEvent(model.EventTyping, roomID, userID)
+	payload := model.TypingPayload{Typing: typing}
+	if typing {
+		payload.ExpiresInMs = tu.ttl.Milliseconds()
+	}
+	_ = event.SetPayload(payload)
+	if err := tu.pubSubRepo.Publish(ctx, redis.RoomTopic(roomID), event); err != nil {
+		log.Printf("[TypingUseCase] Failed to publish typing of %s to room %s: %v", userID, roomID, err)
+	}
+}
@@ -0,0 +1,138 @@
+// usecase/typing_usecase_test.go
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"chat-websocket/model"
+)
+
+func TestTypingUseCase(t *testing.T) {
+	tests := []struct {
+		name     string
+		muted    bool
+		run      func(ctx context.Context, env *testEnv, phone, laptop *model.Client) error
+		want     []bool // Typing states announced to another member of the room.
+		wantCode string
+	}{
+		{
+			name: "starts are coalesced across devices",
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) error {
+				_ = env.typing.Start(ctx, phone, "lobby")
+				return env.typing.Start(ctx, laptop, "lobby")
+			},
+			want: []bool{true},
+		},
+		{
+			name: "stop on the only typing device",
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) error {
+				_ = env.typing.Start(ctx, phone, "lobby")
+				env.typing.Stop(ctx, phone, "lobby")
+				return nil
+			},
+			want: []bool{true, false},
+		},
+		{
+			name: "stop while another device types",
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) error {
+				_ = env.typing.Start(ctx, phone, "lobby")
+				_ = env.typing.Start(ctx, laptop, "lobby")
+				env.typing.Stop(ctx, phone, "lobby")
+				return nil
+			},
+			want: []bool{true},
+		},
+		{
+			name: "disconnect while another device types",
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) error {
+				_ = env.typing.Start(ctx, phone, "lobby")
+				_ = env.typing.Start(ctx, laptop, "lobby")
+				env.typing.Disconnect(ctx, laptop)
+				return nil
+			},
+			want: []bool{true},
+		},
+		{
+			name: "last device stops",
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) error {
+				_ = env.typing.Start(ctx, phone, "lobby")
+				_ = env.typing.Start(ctx, laptop, "lobby")
+				env.typing.Stop(ctx, phone, "lobby")
+				env.typing.Disconnect(ctx, laptop)
+				return nil
+			},
+			want: []bool{true, false},
+		},
+		{
+			name: "stop without a start",
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) error {
+				env.typing.Stop(ctx, phone, "lobby")
+				return nil
+			},
+			want: nil,
+		},
+		{
+			name:  "muted users are refused",
+			muted: true,
+			run: func(ctx context.Context, env *testEnv, phone, laptop *model.Client) error {
+				return env.typing.Start(ctx, phone, "lobby")
+			},
+			want:     nil,
+			wantCode: model.ErrCodeForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			env := newTestEnv(t)
+			observer := env.connect(t, "bob-1", "bob")
+			phone := env.connect(t, "alice-1", "alice")
+			laptop := env.connect(t, "alice-2", "alice")
+			for _, client := range []*testClient{observer, phone, laptop} {
+				if err := env.rooms.JoinRoom(ctx, client.Client, "lobby"); err != nil {
+					t.Fatalf("join: %v", err)
+				}
+			}
+			if tt.muted {
+				mute := &model.RoomSanction{RoomID: "lobby", UserID: "alice", Kind: model.SanctionMute, ModeratorID: "bob", CreatedAt: time.Now()}
+				if err := env.sanctions.SaveSanction(mute); err != nil {
+					t.Fatalf("mute: %v", err)
+				}
+			}
+			observer.received()
+
+			checkCode(t, tt.run(ctx, env, phone.Client, laptop.Client), tt.wantCode)
+
+			var got []bool
+			timeout := time.After(settle)
+		collect:
+			for {
+				select {
+				case event := <-observer.events:
+					if event.Type != model.EventTyping {
+						continue
+					}
+					var payload model.TypingPayload
+					if err := json.Unmarshal(event.Payload, &payload); err != nil {
+						t.Fatalf("decode typing payload: %v", err)
+					}
+					got = append(got, payload.Typing)
+				case <-timeout:
+					break collect
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("typing events = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("typing events = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}